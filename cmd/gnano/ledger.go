@@ -0,0 +1,167 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/Shryder/gnano/database"
+	"github.com/Shryder/gnano/node"
+)
+
+// gnano ledger <verify|export|import|prune> [flags]
+func runLedger(config *node.Config, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing ledger command, expected verify, export, import or prune")
+	}
+
+	switch args[0] {
+	case "verify":
+		return runLedgerVerify(config, args[1:])
+	case "export":
+		return runLedgerExport(config, args[1:])
+	case "import":
+		return runLedgerImport(config, args[1:])
+	case "prune":
+		return runLedgerPrune(config, args[1:])
+	}
+
+	return fmt.Errorf("unknown ledger command %s", args[0])
+}
+
+// gnano ledger verify --backend json
+func runLedgerVerify(config *node.Config, args []string) error {
+	flags := flag.NewFlagSet("ledger verify", flag.ExitOnError)
+	backend_name := flags.String("backend", config.Database.Backend, "Backend holding the ledger to verify")
+	flags.Parse(args)
+
+	genesis, err := loadGenesisBlock()
+	if err != nil {
+		return fmt.Errorf("error loading genesis file: %w", err)
+	}
+
+	db := database.New(&config.Database)
+
+	backend, err := db.OpenBackend(*backend_name)
+	if err != nil {
+		return err
+	}
+
+	defer backend.Cleanup()
+
+	report, err := database.VerifyLedger(backend, genesis)
+	if err != nil {
+		return err
+	}
+
+	log.Println("Verified", report.Blocks, "blocks (", report.Cemented, "cemented ) in", report.Accounts, "accounts")
+
+	if len(report.Issues) > 0 {
+		return fmt.Errorf("found %d inconsistencies in the ledger", len(report.Issues))
+	}
+
+	log.Println("No inconsistencies found")
+
+	return nil
+}
+
+// gnano ledger export --out ledger.snapshot
+func runLedgerExport(config *node.Config, args []string) error {
+	flags := flag.NewFlagSet("ledger export", flag.ExitOnError)
+	backend_name := flags.String("backend", config.Database.Backend, "Backend holding the ledger to export")
+	out := flags.String("out", "ledger.snapshot", "Snapshot file to write")
+	flags.Parse(args)
+
+	db := database.New(&config.Database)
+
+	backend, err := db.OpenBackend(*backend_name)
+	if err != nil {
+		return err
+	}
+
+	defer backend.Cleanup()
+
+	// Written next to the destination first so that a failed export doesn't leave a truncated snapshot behind
+	temp_path := *out + ".tmp"
+	file, err := os.Create(temp_path)
+	if err != nil {
+		return err
+	}
+
+	err = database.ExportLedger(backend, file)
+	if err == nil {
+		err = file.Sync()
+	}
+
+	close_err := file.Close()
+	if err == nil {
+		err = close_err
+	}
+
+	if err != nil {
+		os.Remove(temp_path)
+		return err
+	}
+
+	return os.Rename(temp_path, *out)
+}
+
+// gnano ledger import --in ledger.snapshot
+func runLedgerImport(config *node.Config, args []string) error {
+	flags := flag.NewFlagSet("ledger import", flag.ExitOnError)
+	backend_name := flags.String("backend", config.Database.Backend, "Empty backend to import the ledger into")
+	in := flags.String("in", "ledger.snapshot", "Snapshot file to read")
+	flags.Parse(args)
+
+	file, err := os.Open(*in)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	db := database.New(&config.Database)
+
+	backend, err := db.OpenBackend(*backend_name)
+	if err != nil {
+		return err
+	}
+
+	defer backend.Cleanup()
+
+	return database.ImportLedger(backend, file)
+}
+
+// gnano ledger prune --depth 1000
+func runLedgerPrune(config *node.Config, args []string) error {
+	flags := flag.NewFlagSet("ledger prune", flag.ExitOnError)
+	backend_name := flags.String("backend", config.Database.Backend, "Backend holding the ledger to prune")
+	depth := flags.Uint64("depth", config.Database.PruningDepth, "Cemented blocks kept per account")
+	flags.Parse(args)
+
+	if *depth == 0 {
+		return fmt.Errorf("depth has to be at least 1")
+	}
+
+	db := database.New(&config.Database)
+	db.Config.PruningDepth = *depth
+
+	backend, err := db.OpenBackend(*backend_name)
+	if err != nil {
+		return err
+	}
+
+	defer backend.Cleanup()
+
+	db.Backend = backend
+
+	pruned, err := db.Prune()
+	if err != nil {
+		return err
+	}
+
+	log.Println("Pruned", pruned, "blocks, the ledger now holds", backend.GetBlockCount(), "blocks and", backend.GetPrunedCount(), "pruned ones")
+
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/signal"
 
+	"github.com/Shryder/gnano/database/ledger"
 	"github.com/Shryder/gnano/node"
 	"github.com/Shryder/gnano/types"
 	"github.com/naoina/toml"
@@ -67,6 +68,26 @@ func main() {
 
 	log.Println("Genesis block:", genesisBlock)
 
+	// Every command that stores blocks needs to recognize the genesis block
+	ledger.SetGenesis(genesisBlock)
+
+	switch flag.Arg(0) {
+	case "migrate":
+		err = runMigrate(config, flag.Args()[1:])
+		if err != nil {
+			log.Fatal("Error migrating ledger:", err)
+		}
+
+		return
+	case "ledger":
+		err = runLedger(config, flag.Args()[1:])
+		if err != nil {
+			log.Fatal("Error running ledger command: ", err)
+		}
+
+		return
+	}
+
 	node, err := node.New(config, genesisBlock)
 	if err != nil {
 		log.Fatal("Error initiation node instance:", err)
@@ -81,10 +102,10 @@ func main() {
 
 		log.Println("Cleaning up before shutting down...")
 		node.Cleanup()
-		log.Println("Done cleaning up.")
-
-		os.Exit(0)
 	}()
 
+	// Returns once Cleanup is done
 	node.Start()
+
+	log.Println("Done cleaning up.")
 }
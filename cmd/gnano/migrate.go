@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"strings"
+
+	"github.com/Shryder/gnano/database"
+	"github.com/Shryder/gnano/node"
+)
+
+// gnano migrate --from json --to badger
+func runMigrate(config *node.Config, args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	from := flags.String("from", "json", "Backend to read the ledger from")
+	to := flags.String("to", "badger", "Backend to write the ledger to")
+	flags.Parse(args)
+
+	if strings.EqualFold(*from, *to) {
+		return errors.New("source and target backends must be different")
+	}
+
+	db := database.New(&config.Database)
+
+	source, err := db.OpenBackend(*from)
+	if err != nil {
+		return err
+	}
+
+	defer source.Cleanup()
+
+	target, err := db.OpenBackend(*to)
+	if err != nil {
+		return err
+	}
+
+	defer target.Cleanup()
+
+	return database.MigrateLedger(source, target)
+}
@@ -0,0 +1,163 @@
+package database
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"log"
+
+	"github.com/Shryder/gnano/database/ledger"
+	"github.com/Shryder/gnano/types"
+	"github.com/dgraph-io/badger/v3"
+)
+
+func getAccountInfo(txn *badger.Txn, address *types.Address) (*ledger.AccountInfo, error) {
+	item, err := txn.Get(accountKey(address))
+	if err != nil {
+		return nil, err
+	}
+
+	var info ledger.AccountInfo
+	err = item.Value(func(value []byte) error {
+		return json.Unmarshal(value, &info)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+func putAccountInfo(txn *badger.Txn, address *types.Address, info *ledger.AccountInfo) error {
+	info_json, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+
+	return txn.Set(accountKey(address), info_json)
+}
+
+func (backend *BadgerBackend) GetAccount(address *types.Address) *types.Account {
+	var account *types.Account
+	err := backend.Badger.View(func(txn *badger.Txn) error {
+		info, err := getAccountInfo(txn, address)
+		if err != nil {
+			return err
+		}
+
+		frontier, err := getBlock(txn, info.Frontier)
+		if err != nil {
+			log.Println("Account's frontier was not found.")
+			return err
+		}
+
+		account = &types.Account{
+			Frontier: *frontier,
+			Sideband: *info.Sideband,
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		if !errors.Is(err, badger.ErrKeyNotFound) {
+			log.Println("Error reading account", address.ToNanoAddress(), "from badger:", err)
+		}
+
+		return nil
+	}
+
+	return account
+}
+
+func (backend *BadgerBackend) GetAccountCount() uint64 {
+	return backend.readCounter(META_ACCOUNT_COUNT)
+}
+
+// Returns the hashes of the account's chain starting from the frontier all the way down to the open block
+func (backend *BadgerBackend) GetAccountChain(address *types.Address) []string {
+	chain := make([]string, 0)
+
+	err := backend.Badger.View(func(txn *badger.Txn) error {
+		info, err := getAccountInfo(txn, address)
+		if err != nil {
+			return err
+		}
+
+		cursor := info.Frontier
+		for {
+			block, err := getBlock(txn, cursor)
+			if err != nil {
+				return err
+			}
+
+			chain = append(chain, block.Hash.ToHexString())
+			if block.IsOpenBlock() {
+				return nil
+			}
+
+			cursor = block.Previous
+		}
+	})
+
+	if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+		log.Println("Error reading account chain", address.ToNanoAddress(), "from badger:", err)
+	}
+
+	return chain
+}
+
+func (backend *BadgerBackend) ForEachAccount(callback func(address *types.Address) error) error {
+	return backend.Badger.View(func(txn *badger.Txn) error {
+		options := badger.DefaultIteratorOptions
+		options.PrefetchValues = false
+		options.Prefix = []byte{PREFIX_ACCOUNT}
+
+		it := txn.NewIterator(options)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			var address types.Address
+			copy(address[:], it.Item().Key()[1:])
+
+			err := callback(&address)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Seeks to a random position in the accounts table and returns the first account found from there
+func (backend *BadgerBackend) GetRandomAccountAddress() *types.Address {
+	var seek types.Address
+	rand.Read(seek[:])
+
+	var address *types.Address
+	backend.Badger.View(func(txn *badger.Txn) error {
+		options := badger.DefaultIteratorOptions
+		options.PrefetchValues = false
+		options.Prefix = []byte{PREFIX_ACCOUNT}
+
+		it := txn.NewIterator(options)
+		defer it.Close()
+
+		it.Seek(accountKey(&seek))
+		if !it.Valid() {
+			// Wrap around to the first account
+			it.Rewind()
+		}
+
+		if it.Valid() {
+			address = new(types.Address)
+			copy(address[:], it.Item().Key()[1:])
+		}
+
+		return nil
+	})
+
+	return address
+}
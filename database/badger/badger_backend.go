@@ -1,36 +1,136 @@
 package database
 
 import (
+	"encoding/binary"
+	"errors"
 	"log"
 
+	"github.com/Shryder/gnano/types"
 	"github.com/dgraph-io/badger/v3"
 )
 
 type BadgerBackend struct {
 	Badger *badger.DB
+	Path   string
 }
 
-func (backend BadgerBackend) BackendName() string {
+func (backend *BadgerBackend) BackendName() string {
 	return "Badger"
 }
 
-func (backend BadgerBackend) AddNodeIP(ip string) error {
-	return nil
+func (backend *BadgerBackend) Cleanup() error {
+	return backend.Badger.Close()
 }
 
-func (backend BadgerBackend) Cleanup() error {
-	return backend.Badger.Close()
+// Reads a uint64 counter from the meta table, missing counters are 0
+func getCounter(txn *badger.Txn, key []byte) (uint64, error) {
+	item, err := txn.Get(key)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	value, err := item.ValueCopy(nil)
+	if err != nil {
+		return 0, err
+	}
+
+	return binary.BigEndian.Uint64(value), nil
+}
+
+func incrementCounter(txn *badger.Txn, key []byte, delta uint64) error {
+	count, err := getCounter(txn, key)
+	if err != nil {
+		return err
+	}
+
+	return txn.Set(key, binary.BigEndian.AppendUint64(make([]byte, 0, 8), count+delta))
+}
+
+func decrementCounter(txn *badger.Txn, key []byte, delta uint64) error {
+	count, err := getCounter(txn, key)
+	if err != nil {
+		return err
+	}
+
+	if delta > count {
+		delta = count
+	}
+
+	return txn.Set(key, binary.BigEndian.AppendUint64(make([]byte, 0, 8), count-delta))
+}
+
+func (backend *BadgerBackend) readCounter(key []byte) uint64 {
+	count := uint64(0)
+	err := backend.Badger.View(func(txn *badger.Txn) error {
+		var err error
+		count, err = getCounter(txn, key)
+
+		return err
+	})
+
+	if err != nil {
+		log.Println("Error reading counter", string(key[1:]), "from badger:", err)
+	}
+
+	return count
+}
+
+// Fill a freshly created database with default values
+func (backend *BadgerBackend) initializeIfEmpty(initialWeights map[string]types.Amount) error {
+	return backend.Badger.Update(func(txn *badger.Txn) error {
+		_, err := txn.Get(META_INITIALIZED)
+		if err == nil {
+			return nil
+		}
+
+		if !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+
+		for address_str, weight := range initialWeights {
+			address, err := types.StringPublicKeyToAddress(address_str)
+			if err != nil {
+				return err
+			}
+
+			err = txn.Set(weightKey(address), weight.BytesBE())
+			if err != nil {
+				return err
+			}
+		}
+
+		return txn.Set(META_INITIALIZED, []byte{1})
+	})
 }
 
-func Initialize(path string) (*BadgerBackend, error) {
+func Initialize(path string, initialWeights map[string]types.Amount) (*BadgerBackend, error) {
 	log.Println("Loading Badger backend from", path)
 
-	badger, err := badger.Open(badger.DefaultOptions(path))
+	err := finishRebuild(path)
 	if err != nil {
 		return nil, err
 	}
 
-	return &BadgerBackend{
+	badger, err := openBadger(path)
+	if err != nil {
+		return nil, err
+	}
+
+	backend := &BadgerBackend{
 		Badger: badger,
-	}, nil
+		Path:   path,
+	}
+
+	err = backend.initializeIfEmpty(initialWeights)
+	if err != nil {
+		badger.Close()
+
+		return nil, err
+	}
+
+	return backend, nil
 }
@@ -0,0 +1,253 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/Shryder/gnano/database/ledger"
+	"github.com/Shryder/gnano/types"
+	"github.com/dgraph-io/badger/v3"
+)
+
+func (backend *BadgerBackend) GetBlockCount() uint64 {
+	return backend.readCounter(META_BLOCK_COUNT)
+}
+
+func getBlock(txn *badger.Txn, hash *types.Hash) (*types.Block, error) {
+	item, err := txn.Get(blockKey(hash))
+	if err != nil {
+		return nil, err
+	}
+
+	var block types.Block
+	err = item.Value(func(value []byte) error {
+		return json.Unmarshal(value, &block)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &block, nil
+}
+
+func (backend *BadgerBackend) GetBlock(hash *types.Hash) *types.Block {
+	var block *types.Block
+	err := backend.Badger.View(func(txn *badger.Txn) error {
+		var err error
+		block, err = getBlock(txn, hash)
+
+		return err
+	})
+
+	if err != nil {
+		if !errors.Is(err, badger.ErrKeyNotFound) {
+			log.Println("Error reading block", hash.ToHexString(), "from badger:", err)
+		}
+
+		return nil
+	}
+
+	return block
+}
+
+func getSideband(txn *badger.Txn, hash *types.Hash) (*types.Sideband, error) {
+	item, err := txn.Get(sidebandKey(hash))
+	if err != nil {
+		return nil, err
+	}
+
+	var sideband types.Sideband
+	err = item.Value(func(value []byte) error {
+		return json.Unmarshal(value, &sideband)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &sideband, nil
+}
+
+func putSideband(txn *badger.Txn, hash *types.Hash, sideband *types.Sideband) error {
+	sideband_json, err := json.Marshal(sideband)
+	if err != nil {
+		return err
+	}
+
+	return txn.Set(sidebandKey(hash), sideband_json)
+}
+
+// Points the previous block's sideband to block and indexes block by its height
+func linkBlock(txn *badger.Txn, block *types.Block, sideband *types.Sideband) error {
+	err := putSideband(txn, block.Hash, sideband)
+	if err != nil {
+		return err
+	}
+
+	err = txn.Set(heightKey(block.Account, sideband.Height.Uint64()), block.Hash[:])
+	if err != nil {
+		return err
+	}
+
+	if block.IsOpenBlock() {
+		return nil
+	}
+
+	previous, err := getSideband(txn, block.Previous)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		// Stored before blocks had sidebands
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	previous.Successor = block.Hash
+
+	return putSideband(txn, block.Previous, previous)
+}
+
+func putBlock(txn *badger.Txn, block *types.Block) error {
+	// The transaction sees its own writes, so blocks earlier in the batch are visible to the ledger rules
+	changes, err := ledger.ProcessBlock(txnView{txn}, block)
+	if err != nil {
+		return err
+	}
+
+	if changes.NewAccount {
+		err = incrementCounter(txn, META_ACCOUNT_COUNT, 1)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = putAccountInfo(txn, block.Account, changes.Account)
+	if err != nil {
+		return err
+	}
+
+	err = linkBlock(txn, block, changes.Sideband)
+	if err != nil {
+		return err
+	}
+
+	if changes.AddReceivable != nil {
+		err = putReceivable(txn, changes.AddReceivable)
+		if err != nil {
+			return err
+		}
+	}
+
+	if changes.RemoveReceivable != nil {
+		err = txn.Delete(receivableKey(changes.RemoveReceivable))
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, representative := range changes.Representatives() {
+		weight, err := getWeight(txn, votingWeightKey(&representative))
+		if err != nil {
+			return err
+		}
+
+		err = putWeight(txn, votingWeightKey(&representative), changes.AdjustWeight(representative, weight))
+		if err != nil {
+			return err
+		}
+	}
+
+	block_json, err := json.Marshal(block)
+	if err != nil {
+		return err
+	}
+
+	err = txn.Set(blockKey(block.Hash), block_json)
+	if err != nil {
+		return err
+	}
+
+	return incrementCounter(txn, META_BLOCK_COUNT, 1)
+}
+
+func (backend *BadgerBackend) GetBlockSideband(hash *types.Hash) *types.Sideband {
+	var sideband *types.Sideband
+	err := backend.Badger.View(func(txn *badger.Txn) error {
+		var err error
+		sideband, err = getSideband(txn, hash)
+
+		return err
+	})
+
+	if err != nil {
+		if !errors.Is(err, badger.ErrKeyNotFound) {
+			log.Println("Error reading sideband of", hash.ToHexString(), "from badger:", err)
+		}
+
+		return nil
+	}
+
+	return sideband
+}
+
+func (backend *BadgerBackend) GetBlockAtHeight(address *types.Address, height uint64) *types.Block {
+	var block *types.Block
+	err := backend.Badger.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(heightKey(address, height))
+		if err != nil {
+			return err
+		}
+
+		var hash types.Hash
+		err = item.Value(func(value []byte) error {
+			hash.FromSlice(value)
+
+			return nil
+		})
+
+		if err != nil {
+			return err
+		}
+
+		block, err = getBlock(txn, &hash)
+
+		return err
+	})
+
+	if err != nil {
+		if !errors.Is(err, badger.ErrKeyNotFound) {
+			log.Println("Error reading block at height", height, "of", address.ToNanoAddress(), "from badger:", err)
+		}
+
+		return nil
+	}
+
+	return block
+}
+
+func (backend *BadgerBackend) PutBlock(block *types.Block) error {
+	return backend.PutBlocks([]*types.Block{block})
+}
+
+// All blocks are written in a single transaction, which is discarded as soon as one of them is rejected. Batches are
+// capped at ledger.MAX_PUT_BLOCKS so the transaction never grows past badger's size limit.
+func (backend *BadgerBackend) PutBlocks(blocks []*types.Block) error {
+	if len(blocks) > ledger.MAX_PUT_BLOCKS {
+		return fmt.Errorf("%w: got %d", ledger.ErrBatchTooLarge, len(blocks))
+	}
+
+	return backend.Badger.Update(func(txn *badger.Txn) error {
+		for i, block := range blocks {
+			err := putBlock(txn, block)
+			if err != nil {
+				return &ledger.BlockError{Index: i, Hash: block.Hash, Err: err}
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,147 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+
+	"github.com/Shryder/gnano/database/ledger"
+	"github.com/Shryder/gnano/types"
+	"github.com/dgraph-io/badger/v3"
+)
+
+func getConfirmationHeight(txn *badger.Txn, address *types.Address) (*ledger.ConfirmationHeight, error) {
+	item, err := txn.Get(confirmationHeightKey(address))
+	if err != nil {
+		return nil, err
+	}
+
+	var confirmation_height ledger.ConfirmationHeight
+	err = item.Value(func(value []byte) error {
+		return json.Unmarshal(value, &confirmation_height)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &confirmation_height, nil
+}
+
+func putConfirmationHeight(txn *badger.Txn, address *types.Address, confirmation_height *ledger.ConfirmationHeight) error {
+	confirmation_height_json, err := json.Marshal(confirmation_height)
+	if err != nil {
+		return err
+	}
+
+	return txn.Set(confirmationHeightKey(address), confirmation_height_json)
+}
+
+func (backend *BadgerBackend) GetConfirmationHeight(address *types.Address) *ledger.ConfirmationHeight {
+	var confirmation_height *ledger.ConfirmationHeight
+	err := backend.Badger.View(func(txn *badger.Txn) error {
+		var err error
+		confirmation_height, err = getConfirmationHeight(txn, address)
+
+		return err
+	})
+
+	if err != nil {
+		if !errors.Is(err, badger.ErrKeyNotFound) {
+			log.Println("Error reading confirmation height of", address.ToNanoAddress(), "from badger:", err)
+		}
+
+		return nil
+	}
+
+	return confirmation_height
+}
+
+func (backend *BadgerBackend) GetCementedCount() uint64 {
+	return backend.readCounter(META_CEMENTED_COUNT)
+}
+
+func (backend *BadgerBackend) CementBlock(hash *types.Hash) ([]*types.Hash, error) {
+	var cemented []*types.Hash
+	err := backend.Badger.Update(func(txn *badger.Txn) error {
+		cementing, err := ledger.Cement(txnView{txn}, hash)
+		if err != nil {
+			return err
+		}
+
+		for account, confirmation_height := range cementing.Heights {
+			err = putConfirmationHeight(txn, &account, &confirmation_height)
+			if err != nil {
+				return err
+			}
+		}
+
+		cemented = cementing.Blocks
+
+		return incrementCounter(txn, META_CEMENTED_COUNT, uint64(len(cementing.Blocks)))
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cemented, nil
+}
+
+// Every block stored before confirmation heights existed was cemented, so each account is confirmed up to its frontier.
+// Ledgers created before schema versions existed may already track them, those are marked with META_CONFIRMATION_HEIGHTS.
+func (backend *BadgerBackend) BackfillConfirmationHeights() error {
+	return backend.Badger.Update(func(txn *badger.Txn) error {
+		_, err := txn.Get(META_CONFIRMATION_HEIGHTS)
+		if err == nil {
+			return nil
+		}
+
+		if !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+
+		options := badger.DefaultIteratorOptions
+		options.Prefix = []byte{PREFIX_ACCOUNT}
+
+		it := txn.NewIterator(options)
+		defer it.Close()
+
+		cemented_count := uint64(0)
+		for it.Rewind(); it.Valid(); it.Next() {
+			var info ledger.AccountInfo
+			err := it.Item().Value(func(value []byte) error {
+				return json.Unmarshal(value, &info)
+			})
+
+			if err != nil {
+				return err
+			}
+
+			if info.Frontier == nil || info.Sideband == nil {
+				continue
+			}
+
+			var address types.Address
+			copy(address[:], it.Item().Key()[1:])
+
+			err = putConfirmationHeight(txn, &address, &ledger.ConfirmationHeight{
+				Height:   info.Sideband.Height.Uint64(),
+				Frontier: *info.Frontier,
+			})
+
+			if err != nil {
+				return err
+			}
+
+			cemented_count += info.Sideband.Height.Uint64()
+		}
+
+		err = incrementCounter(txn, META_CEMENTED_COUNT, cemented_count)
+		if err != nil {
+			return err
+		}
+
+		return txn.Set(META_CONFIRMATION_HEIGHTS, []byte{1})
+	})
+}
@@ -0,0 +1,89 @@
+package database
+
+import (
+	"encoding/binary"
+
+	"github.com/Shryder/gnano/database/ledger"
+	"github.com/Shryder/gnano/types"
+)
+
+// Every table lives in the same keyspace, prefixed by a single byte
+const (
+	PREFIX_META      byte = 'm' // m + name => backend metadata (counters, ...)
+	PREFIX_BLOCK     byte = 'b' // b + hash => block
+	PREFIX_SIDEBAND  byte = 's' // s + hash => sideband
+	PREFIX_HEIGHT    byte = 'h' // h + public_key + height => hash
+	PREFIX_ACCOUNT   byte = 'a' // a + public_key => account entry
+	PREFIX_NODE      byte = 'n' // n + ip => discovery_timestamp
+	PREFIX_WEIGHT    byte = 'w' // w + public_key => weight seeded from weights.json
+	PREFIX_VOTING    byte = 'v' // v + representative public_key => weight delegated in the ledger
+	PREFIX_PRUNED    byte = 'p' // p + hash => nothing, cemented blocks discarded by pruning
+	PREFIX_UNCHECKED byte = 'u' // u + hash => downloaded block waiting for confirmation
+
+	PREFIX_RECEIVABLE          byte = 'r' // r + destination public_key + send_hash => receivable
+	PREFIX_CONFIRMATION_HEIGHT byte = 'c' // c + public_key => highest cemented block
+)
+
+var (
+	META_BLOCK_COUNT    = metaKey("block_count")
+	META_ACCOUNT_COUNT  = metaKey("account_count")
+	META_CEMENTED_COUNT = metaKey("cemented_count")
+	META_PRUNED_COUNT   = metaKey("pruned_count")
+	META_INITIALIZED    = metaKey("initialized")
+	META_SCHEMA_VERSION = metaKey("schema_version") // Missing on ledgers written before schema versions existed
+
+	META_CONFIRMATION_HEIGHTS = metaKey("confirmation_heights") // Set once confirmation heights are tracked
+)
+
+func metaKey(name string) []byte {
+	return append([]byte{PREFIX_META}, name...)
+}
+
+func blockKey(hash *types.Hash) []byte {
+	return append([]byte{PREFIX_BLOCK}, hash[:]...)
+}
+
+func sidebandKey(hash *types.Hash) []byte {
+	return append([]byte{PREFIX_SIDEBAND}, hash[:]...)
+}
+
+func prunedKey(hash *types.Hash) []byte {
+	return append([]byte{PREFIX_PRUNED}, hash[:]...)
+}
+
+func uncheckedKey(hash *types.Hash) []byte {
+	return append([]byte{PREFIX_UNCHECKED}, hash[:]...)
+}
+
+// Heights are big endian so that the blocks of an account are iterated in chain order
+func heightKey(address *types.Address, height uint64) []byte {
+	return binary.BigEndian.AppendUint64(append([]byte{PREFIX_HEIGHT}, address[:]...), height)
+}
+
+func accountKey(address *types.Address) []byte {
+	return append([]byte{PREFIX_ACCOUNT}, address[:]...)
+}
+
+func nodeKey(ip string) []byte {
+	return append([]byte{PREFIX_NODE}, ip...)
+}
+
+func weightKey(address *types.Address) []byte {
+	return append([]byte{PREFIX_WEIGHT}, address[:]...)
+}
+
+func votingWeightKey(address *types.Address) []byte {
+	return append([]byte{PREFIX_VOTING}, address[:]...)
+}
+
+func confirmationHeightKey(address *types.Address) []byte {
+	return append([]byte{PREFIX_CONFIRMATION_HEIGHT}, address[:]...)
+}
+
+func receivableKey(key *ledger.ReceivableKey) []byte {
+	return append(append([]byte{PREFIX_RECEIVABLE}, key.Destination[:]...), key.SendHash[:]...)
+}
+
+func receivablePrefix(destination *types.Address) []byte {
+	return append([]byte{PREFIX_RECEIVABLE}, destination[:]...)
+}
@@ -0,0 +1,67 @@
+package database
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+func (backend *BadgerBackend) AddNodeIPs(addresses []string) error {
+	return backend.Badger.Update(func(txn *badger.Txn) error {
+		now := binary.BigEndian.AppendUint64(make([]byte, 0, 8), uint64(time.Now().Unix()))
+
+		for _, address := range addresses {
+			_, err := txn.Get(nodeKey(address))
+			if err == nil {
+				// Already exists
+				continue
+			}
+
+			if !errors.Is(err, badger.ErrKeyNotFound) {
+				return err
+			}
+
+			err = txn.Set(nodeKey(address), now)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (backend *BadgerBackend) GetNodeIPs() (map[string]uint, error) {
+	nodes := make(map[string]uint)
+
+	err := backend.Badger.View(func(txn *badger.Txn) error {
+		options := badger.DefaultIteratorOptions
+		options.Prefix = []byte{PREFIX_NODE}
+
+		it := txn.NewIterator(options)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+			err := item.Value(func(value []byte) error {
+				nodes[string(item.Key()[1:])] = uint(binary.BigEndian.Uint64(value))
+
+				return nil
+			})
+
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return nodes, nil
+}
@@ -0,0 +1,124 @@
+package database
+
+import (
+	"encoding/binary"
+	"errors"
+	"log"
+
+	"github.com/Shryder/gnano/types"
+	"github.com/dgraph-io/badger/v3"
+)
+
+// Amount of blocks discarded per transaction, badger refuses transactions that grow too big
+const PRUNE_BATCH_SIZE = 1024
+
+func isBlockPruned(txn *badger.Txn, hash *types.Hash) (bool, error) {
+	_, err := txn.Get(prunedKey(hash))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func (backend *BadgerBackend) IsBlockPruned(hash *types.Hash) bool {
+	pruned := false
+	err := backend.Badger.View(func(txn *badger.Txn) error {
+		var err error
+		pruned, err = isBlockPruned(txn, hash)
+
+		return err
+	})
+
+	if err != nil {
+		log.Println("Error reading pruned entry of", hash.ToHexString(), "from badger:", err)
+	}
+
+	return pruned
+}
+
+func (backend *BadgerBackend) GetPrunedCount() uint64 {
+	return backend.readCounter(META_PRUNED_COUNT)
+}
+
+// Discards up to limit of the lowest blocks of the account that are at or below cutoff. Pruned blocks lose their
+// height index entry, so the index is used to find the lowest block left.
+func pruneAccountBatch(txn *badger.Txn, address *types.Address, cutoff uint64, limit int) (uint64, error) {
+	options := badger.DefaultIteratorOptions
+	options.Prefix = append([]byte{PREFIX_HEIGHT}, address[:]...)
+
+	it := txn.NewIterator(options)
+
+	heights := make([]uint64, 0)
+	hashes := make([]types.Hash, 0)
+	for it.Seek(heightKey(address, 1)); it.Valid() && len(hashes) < limit; it.Next() {
+		height := binary.BigEndian.Uint64(it.Item().Key()[len(options.Prefix):])
+		if height > cutoff {
+			break
+		}
+
+		var hash types.Hash
+		err := it.Item().Value(func(value []byte) error {
+			hash.FromSlice(value)
+
+			return nil
+		})
+
+		if err != nil {
+			it.Close()
+			return 0, err
+		}
+
+		heights = append(heights, height)
+		hashes = append(hashes, hash)
+	}
+
+	it.Close()
+
+	for i := range hashes {
+		for _, key := range [][]byte{blockKey(&hashes[i]), sidebandKey(&hashes[i]), heightKey(address, heights[i])} {
+			err := txn.Delete(key)
+			if err != nil {
+				return 0, err
+			}
+		}
+
+		err := txn.Set(prunedKey(&hashes[i]), []byte{})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	pruned := uint64(len(hashes))
+	err := decrementCounter(txn, META_BLOCK_COUNT, pruned)
+	if err != nil {
+		return 0, err
+	}
+
+	return pruned, incrementCounter(txn, META_PRUNED_COUNT, pruned)
+}
+
+// Discards the account's cemented blocks that are more than depth blocks below its confirmation height
+func (backend *BadgerBackend) PruneAccount(address *types.Address, depth uint64) (uint64, error) {
+	confirmation_height := backend.GetConfirmationHeight(address)
+	if confirmation_height == nil || depth == 0 || confirmation_height.Height <= depth {
+		return 0, nil
+	}
+
+	cutoff := confirmation_height.Height - depth
+	total := uint64(0)
+	for {
+		pruned := uint64(0)
+		err := backend.Badger.Update(func(txn *badger.Txn) error {
+			var err error
+			pruned, err = pruneAccountBatch(txn, address, cutoff, PRUNE_BATCH_SIZE)
+
+			return err
+		})
+
+		total += pruned
+		if err != nil || pruned < PRUNE_BATCH_SIZE {
+			return total, err
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package database
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// A ledger is rebuilt into a directory next to the live one. Once complete, the staging directory is renamed to the
+// rebuilt one, which marks it as ready to replace the live ledger, and the swap is finished on the next start if it was interrupted.
+const (
+	REBUILD_STAGING_SUFFIX  = ".rebuild"
+	REBUILD_COMPLETE_SUFFIX = ".rebuilt"
+	REBUILD_REPLACED_SUFFIX = ".old"
+)
+
+func openBadger(path string) (*badger.DB, error) {
+	// Sync writes so that blocks are durable once PutBlock returns, like the JSON backend's journal
+	return badger.Open(badger.DefaultOptions(path).WithLoggingLevel(badger.WARNING).WithSyncWrites(true))
+}
+
+func exists(path string) (bool, error) {
+	_, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// Persists renames done inside the directory
+func syncDir(dir string) error {
+	file, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	return file.Sync()
+}
+
+// Opens an empty ledger to rebuild this one into, whatever an interrupted rebuild left in it is discarded
+func (backend *BadgerBackend) OpenRebuild() (*BadgerBackend, error) {
+	staging := backend.Path + REBUILD_STAGING_SUFFIX
+
+	err := os.RemoveAll(staging)
+	if err != nil {
+		return nil, err
+	}
+
+	return Initialize(staging, nil)
+}
+
+// Swaps the rebuilt ledger in place of this one, both are closed and this backend is reopened on the rebuilt ledger.
+// The rebuilt ledger is at the same schema version as this one.
+func (backend *BadgerBackend) ReplaceWith(rebuilt *BadgerBackend) error {
+	err := rebuilt.SetSchemaVersion(backend.GetSchemaVersion())
+	if err != nil {
+		return err
+	}
+
+	err = rebuilt.Badger.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(rebuilt.Path, backend.Path+REBUILD_COMPLETE_SUFFIX)
+	if err != nil {
+		return err
+	}
+
+	err = syncDir(filepath.Dir(backend.Path))
+	if err != nil {
+		return err
+	}
+
+	err = backend.Badger.Close()
+	if err != nil {
+		return err
+	}
+
+	err = finishRebuild(backend.Path)
+	if err != nil {
+		return err
+	}
+
+	backend.Badger, err = openBadger(backend.Path)
+
+	return err
+}
+
+// Moves a complete rebuild in place of the ledger at path and drops the ledger it replaced, the steps left undone by
+// an interrupted swap are picked up where they stopped. Called before the ledger is opened.
+func finishRebuild(path string) error {
+	complete := path + REBUILD_COMPLETE_SUFFIX
+	replaced := path + REBUILD_REPLACED_SUFFIX
+
+	found, err := exists(complete)
+	if err != nil {
+		return err
+	}
+
+	if found {
+		log.Println("Replacing the ledger at", path, "with its rebuilt copy")
+
+		live, err := exists(path)
+		if err != nil {
+			return err
+		}
+
+		// Otherwise the live ledger was already moved out of the way
+		if live {
+			err = os.RemoveAll(replaced)
+			if err != nil {
+				return err
+			}
+
+			err = os.Rename(path, replaced)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = os.Rename(complete, path)
+		if err != nil {
+			return err
+		}
+
+		err = syncDir(filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+	}
+
+	return os.RemoveAll(replaced)
+}
@@ -0,0 +1,94 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+
+	"github.com/Shryder/gnano/database/ledger"
+	"github.com/Shryder/gnano/types"
+	"github.com/dgraph-io/badger/v3"
+)
+
+func getReceivable(txn *badger.Txn, key *ledger.ReceivableKey) (*ledger.ReceivableInfo, error) {
+	item, err := txn.Get(receivableKey(key))
+	if err != nil {
+		return nil, err
+	}
+
+	var info ledger.ReceivableInfo
+	err = item.Value(func(value []byte) error {
+		return json.Unmarshal(value, &info)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+func putReceivable(txn *badger.Txn, receivable *ledger.Receivable) error {
+	info_json, err := json.Marshal(receivable.Info)
+	if err != nil {
+		return err
+	}
+
+	return txn.Set(receivableKey(&receivable.Key), info_json)
+}
+
+func (backend *BadgerBackend) GetReceivable(destination *types.Address, sendHash *types.Hash) *ledger.ReceivableInfo {
+	var info *ledger.ReceivableInfo
+	err := backend.Badger.View(func(txn *badger.Txn) error {
+		var err error
+		info, err = getReceivable(txn, &ledger.ReceivableKey{Destination: *destination, SendHash: *sendHash})
+
+		return err
+	})
+
+	if err != nil {
+		if !errors.Is(err, badger.ErrKeyNotFound) {
+			log.Println("Error reading receivable", sendHash.ToHexString(), "from badger:", err)
+		}
+
+		return nil
+	}
+
+	return info
+}
+
+func (backend *BadgerBackend) GetReceivables(destination *types.Address) map[types.Hash]ledger.ReceivableInfo {
+	receivables := make(map[types.Hash]ledger.ReceivableInfo)
+
+	err := backend.Badger.View(func(txn *badger.Txn) error {
+		options := badger.DefaultIteratorOptions
+		options.Prefix = receivablePrefix(destination)
+
+		it := txn.NewIterator(options)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			var hash types.Hash
+			copy(hash[:], it.Item().Key()[1+32:])
+
+			var info ledger.ReceivableInfo
+			err := it.Item().Value(func(value []byte) error {
+				return json.Unmarshal(value, &info)
+			})
+
+			if err != nil {
+				return err
+			}
+
+			receivables[hash] = info
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		log.Println("Error reading receivables of", destination.ToNanoAddress(), "from badger:", err)
+	}
+
+	return receivables
+}
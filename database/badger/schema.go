@@ -0,0 +1,198 @@
+package database
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+
+	"github.com/Shryder/gnano/database/ledger"
+	"github.com/Shryder/gnano/types"
+	"github.com/dgraph-io/badger/v3"
+)
+
+// Sidebands rewritten per transaction by BackfillSubtypes, and blocks by InferLegacySendTypes
+const SUBTYPE_BATCH_SIZE = 1024
+
+func (backend *BadgerBackend) GetSchemaVersion() uint64 {
+	return backend.readCounter(META_SCHEMA_VERSION)
+}
+
+func (backend *BadgerBackend) SetSchemaVersion(version uint64) error {
+	return backend.Badger.Update(func(txn *badger.Txn) error {
+		return txn.Set(META_SCHEMA_VERSION, binary.BigEndian.AppendUint64(make([]byte, 0, 8), version))
+	})
+}
+
+// Legacy send blocks used to be stored without their type, every other block type was set when parsing it
+func (backend *BadgerBackend) InferLegacySendTypes() error {
+	sends := make([]*types.Block, 0, SUBTYPE_BATCH_SIZE)
+
+	err := backend.Badger.View(func(txn *badger.Txn) error {
+		options := badger.DefaultIteratorOptions
+		options.Prefix = []byte{PREFIX_BLOCK}
+
+		it := txn.NewIterator(options)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			var block types.Block
+			err := it.Item().Value(func(value []byte) error {
+				return json.Unmarshal(value, &block)
+			})
+
+			if err != nil {
+				return err
+			}
+
+			if block.Type != 0 {
+				continue
+			}
+
+			block.Type = types.BLOCK_TYPE_SEND
+			sends = append(sends, &block)
+
+			if len(sends) == SUBTYPE_BATCH_SIZE {
+				err = backend.writeBlocks(sends)
+				if err != nil {
+					return err
+				}
+
+				sends = sends[:0]
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return backend.writeBlocks(sends)
+}
+
+// Overwrites stored blocks as they are, without going through the ledger rules
+func (backend *BadgerBackend) writeBlocks(blocks []*types.Block) error {
+	return backend.Badger.Update(func(txn *badger.Txn) error {
+		for _, block := range blocks {
+			block_json, err := json.Marshal(block)
+			if err != nil {
+				return err
+			}
+
+			err = txn.Set(blockKey(block.Hash), block_json)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+type subtypeUpdate struct {
+	hash     types.Hash
+	sideband *types.Sideband
+}
+
+func (backend *BadgerBackend) writeSubtypes(updates []subtypeUpdate) error {
+	return backend.Badger.Update(func(txn *badger.Txn) error {
+		for _, update := range updates {
+			err := putSideband(txn, &update.hash, update.sideband)
+			if err != nil {
+				return err
+			}
+
+			// Accounts keep a copy of their frontier's sideband
+			info, err := getAccountInfo(txn, update.sideband.Account)
+			if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+				return err
+			}
+
+			if info != nil && *info.Frontier == update.hash {
+				info.Sideband = update.sideband
+
+				err = putAccountInfo(txn, update.sideband.Account, info)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// Blocks whose previous block was pruned are left as unknown, the balance they started from is gone
+func (backend *BadgerBackend) BackfillSubtypes() error {
+	updates := make([]subtypeUpdate, 0, SUBTYPE_BATCH_SIZE)
+
+	err := backend.Badger.View(func(txn *badger.Txn) error {
+		options := badger.DefaultIteratorOptions
+		options.Prefix = []byte{PREFIX_SIDEBAND}
+
+		it := txn.NewIterator(options)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			var hash types.Hash
+			copy(hash[:], it.Item().Key()[1:])
+
+			var sideband types.Sideband
+			err := it.Item().Value(func(value []byte) error {
+				return json.Unmarshal(value, &sideband)
+			})
+
+			if err != nil {
+				return err
+			}
+
+			if sideband.Subtype != types.BLOCK_SUBTYPE_UNKNOWN {
+				continue
+			}
+
+			block, err := getBlock(txn, &hash)
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				continue
+			}
+
+			if err != nil {
+				return err
+			}
+
+			previous_balance := types.Amount{}
+			if !block.IsOpenBlock() {
+				previous, err := getSideband(txn, block.Previous)
+				if errors.Is(err, badger.ErrKeyNotFound) {
+					continue
+				}
+
+				if err != nil {
+					return err
+				}
+
+				previous_balance = previous.Balance
+			}
+
+			sideband.Subtype = ledger.ResolveSubtype(block, previous_balance, sideband.Balance)
+			updates = append(updates, subtypeUpdate{hash: hash, sideband: &sideband})
+
+			if len(updates) == SUBTYPE_BATCH_SIZE {
+				err = backend.writeSubtypes(updates)
+				if err != nil {
+					return err
+				}
+
+				updates = updates[:0]
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return backend.writeSubtypes(updates)
+}
@@ -0,0 +1,97 @@
+package database
+
+import (
+	"encoding/json"
+
+	"github.com/Shryder/gnano/database/ledger"
+	"github.com/Shryder/gnano/types"
+	"github.com/dgraph-io/badger/v3"
+)
+
+// Entries written per transaction, a single one could grow past badger's transaction size limit
+const UNCHECKED_BATCH_SIZE = 1024
+
+func (backend *BadgerBackend) PutUnchecked(entries []*ledger.UncheckedInfo) error {
+	for start := 0; start < len(entries); start += UNCHECKED_BATCH_SIZE {
+		end := start + UNCHECKED_BATCH_SIZE
+		if end > len(entries) {
+			end = len(entries)
+		}
+
+		err := backend.Badger.Update(func(txn *badger.Txn) error {
+			for _, entry := range entries[start:end] {
+				entry_json, err := json.Marshal(entry)
+				if err != nil {
+					return err
+				}
+
+				err = txn.Set(uncheckedKey(entry.Block.Hash), entry_json)
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (backend *BadgerBackend) DeleteUnchecked(hashes []types.Hash) error {
+	for start := 0; start < len(hashes); start += UNCHECKED_BATCH_SIZE {
+		end := start + UNCHECKED_BATCH_SIZE
+		if end > len(hashes) {
+			end = len(hashes)
+		}
+
+		err := backend.Badger.Update(func(txn *badger.Txn) error {
+			for i := range hashes[start:end] {
+				err := txn.Delete(uncheckedKey(&hashes[start+i]))
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (backend *BadgerBackend) ForEachUnchecked(callback func(entry *ledger.UncheckedInfo) error) error {
+	return backend.Badger.View(func(txn *badger.Txn) error {
+		options := badger.DefaultIteratorOptions
+		options.Prefix = []byte{PREFIX_UNCHECKED}
+
+		it := txn.NewIterator(options)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			var entry ledger.UncheckedInfo
+			err := it.Item().Value(func(value []byte) error {
+				return json.Unmarshal(value, &entry)
+			})
+
+			if err != nil {
+				return err
+			}
+
+			err = callback(&entry)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
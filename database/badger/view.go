@@ -0,0 +1,63 @@
+package database
+
+import (
+	"errors"
+
+	"github.com/Shryder/gnano/database/ledger"
+	"github.com/Shryder/gnano/types"
+	"github.com/dgraph-io/badger/v3"
+)
+
+// Implements ledger.View and ledger.CementView on top of a badger transaction
+type txnView struct {
+	txn *badger.Txn
+}
+
+func (view txnView) GetAccountInfo(address *types.Address) (*ledger.AccountInfo, error) {
+	info, err := getAccountInfo(view.txn, address)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, nil
+	}
+
+	return info, err
+}
+
+func (view txnView) GetReceivable(key ledger.ReceivableKey) (*ledger.ReceivableInfo, error) {
+	info, err := getReceivable(view.txn, &key)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, nil
+	}
+
+	return info, err
+}
+
+func (view txnView) GetBlockSideband(hash *types.Hash) (*types.Sideband, error) {
+	sideband, err := getSideband(view.txn, hash)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, nil
+	}
+
+	return sideband, err
+}
+
+func (view txnView) GetBlock(hash *types.Hash) (*types.Block, error) {
+	block, err := getBlock(view.txn, hash)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, nil
+	}
+
+	return block, err
+}
+
+func (view txnView) GetConfirmationHeight(address *types.Address) (*ledger.ConfirmationHeight, error) {
+	confirmation_height, err := getConfirmationHeight(view.txn, address)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, nil
+	}
+
+	return confirmation_height, err
+}
+
+func (view txnView) IsBlockPruned(hash *types.Hash) (bool, error) {
+	return isBlockPruned(view.txn, hash)
+}
@@ -0,0 +1,104 @@
+package database
+
+import (
+	"errors"
+	"log"
+
+	"github.com/Shryder/gnano/types"
+	"github.com/dgraph-io/badger/v3"
+)
+
+// Weights are stored as 16 big endian bytes, a missing key is a weight of 0
+func getWeight(txn *badger.Txn, key []byte) (types.Amount, error) {
+	item, err := txn.Get(key)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return types.Amount{}, nil
+	}
+
+	if err != nil {
+		return types.Amount{}, err
+	}
+
+	weight := types.Amount{}
+	err = item.Value(func(value []byte) error {
+		weight = types.AmountFromBytesBE(value)
+
+		return nil
+	})
+
+	return weight, err
+}
+
+func putWeight(txn *badger.Txn, key []byte, weight types.Amount) error {
+	if weight.IsZero() {
+		return txn.Delete(key)
+	}
+
+	return txn.Set(key, weight.BytesBE())
+}
+
+func (backend *BadgerBackend) readWeight(key []byte, address *types.Address) types.Amount {
+	weight := types.Amount{}
+
+	err := backend.Badger.View(func(txn *badger.Txn) error {
+		var err error
+		weight, err = getWeight(txn, key)
+
+		return err
+	})
+
+	if err != nil {
+		log.Println("Error reading voting weight of", address.ToNanoAddress(), "from badger:", err)
+	}
+
+	return weight
+}
+
+func (backend *BadgerBackend) GetVotingWeight(address *types.Address) types.Amount {
+	return backend.readWeight(votingWeightKey(address), address)
+}
+
+func (backend *BadgerBackend) GetBootstrapWeight(address *types.Address) types.Amount {
+	return backend.readWeight(weightKey(address), address)
+}
+
+func (backend *BadgerBackend) PutBootstrapWeight(address *types.Address, weight types.Amount) error {
+	return backend.Badger.Update(func(txn *badger.Txn) error {
+		return txn.Set(weightKey(address), weight.BytesBE())
+	})
+}
+
+func (backend *BadgerBackend) ForEachVotingWeight(callback func(address *types.Address, weight types.Amount) error) error {
+	return backend.forEachWeight(PREFIX_VOTING, callback)
+}
+
+func (backend *BadgerBackend) ForEachBootstrapWeight(callback func(address *types.Address, weight types.Amount) error) error {
+	return backend.forEachWeight(PREFIX_WEIGHT, callback)
+}
+
+func (backend *BadgerBackend) forEachWeight(prefix byte, callback func(address *types.Address, weight types.Amount) error) error {
+	return backend.Badger.View(func(txn *badger.Txn) error {
+		options := badger.DefaultIteratorOptions
+		options.Prefix = []byte{prefix}
+
+		it := txn.NewIterator(options)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			value, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+
+			var address types.Address
+			copy(address[:], it.Item().Key()[1:])
+
+			err = callback(&address, types.AmountFromBytesBE(value))
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
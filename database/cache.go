@@ -0,0 +1,134 @@
+package database
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/Shryder/gnano/types"
+	"github.com/Shryder/gnano/utils"
+)
+
+type CacheStats struct {
+	Blocks        int    `json:"blocks"`
+	BlockHits     uint64 `json:"block_hits"`
+	BlockMisses   uint64 `json:"block_misses"`
+	Accounts      int    `json:"accounts"`
+	AccountHits   uint64 `json:"account_hits"`
+	AccountMisses uint64 `json:"account_misses"`
+}
+
+// Read-through cache of blocks and accounts in front of any backend. Only found entries are cached,
+// accounts are dropped whenever one of their blocks is stored and blocks whenever anything gets pruned.
+type CachedBackend struct {
+	// Updated atomically, kept first so that they're 64-bit aligned on 32-bit platforms
+	blockHits     uint64
+	blockMisses   uint64
+	accountHits   uint64
+	accountMisses uint64
+
+	DatabaseBackend
+
+	blocks   *utils.LRU // types.Hash => types.Block
+	accounts *utils.LRU // types.Address => types.Account
+
+	// Writes hold it exclusively so that a read that missed can't put an entry the write made stale back into the cache
+	writes sync.RWMutex
+}
+
+func NewCachedBackend(backend DatabaseBackend, block_cache_size uint64, account_cache_size uint64) *CachedBackend {
+	return &CachedBackend{
+		DatabaseBackend: backend,
+
+		blocks:   utils.NewLRU(int(block_cache_size)),
+		accounts: utils.NewLRU(int(account_cache_size)),
+	}
+}
+
+func (cache *CachedBackend) GetBlock(hash *types.Hash) *types.Block {
+	if cached, found := cache.blocks.Get(*hash); found {
+		atomic.AddUint64(&cache.blockHits, 1)
+
+		block := cached.(types.Block)
+		return &block
+	}
+
+	atomic.AddUint64(&cache.blockMisses, 1)
+
+	cache.writes.RLock()
+	defer cache.writes.RUnlock()
+
+	block := cache.DatabaseBackend.GetBlock(hash)
+	if block != nil {
+		cache.blocks.Put(*hash, *block)
+	}
+
+	return block
+}
+
+func (cache *CachedBackend) GetAccount(address *types.Address) *types.Account {
+	if cached, found := cache.accounts.Get(*address); found {
+		atomic.AddUint64(&cache.accountHits, 1)
+
+		account := cached.(types.Account)
+		return &account
+	}
+
+	atomic.AddUint64(&cache.accountMisses, 1)
+
+	cache.writes.RLock()
+	defer cache.writes.RUnlock()
+
+	account := cache.DatabaseBackend.GetAccount(address)
+	if account != nil {
+		cache.accounts.Put(*address, *account)
+	}
+
+	return account
+}
+
+func (cache *CachedBackend) PutBlock(block *types.Block) error {
+	return cache.PutBlocks([]*types.Block{block})
+}
+
+func (cache *CachedBackend) PutBlocks(blocks []*types.Block) error {
+	cache.writes.Lock()
+	defer cache.writes.Unlock()
+
+	err := cache.DatabaseBackend.PutBlocks(blocks)
+	if err != nil {
+		return err
+	}
+
+	// The account of legacy blocks is filled in once they're stored
+	for _, block := range blocks {
+		if block.Account != nil {
+			cache.accounts.Remove(*block.Account)
+		}
+	}
+
+	return nil
+}
+
+func (cache *CachedBackend) PruneAccount(address *types.Address, depth uint64) (uint64, error) {
+	cache.writes.Lock()
+	defer cache.writes.Unlock()
+
+	pruned, err := cache.DatabaseBackend.PruneAccount(address, depth)
+	if pruned > 0 {
+		// The pruned hashes aren't known here
+		cache.blocks.Purge()
+	}
+
+	return pruned, err
+}
+
+func (cache *CachedBackend) Stats() CacheStats {
+	return CacheStats{
+		Blocks:        cache.blocks.Len(),
+		BlockHits:     atomic.LoadUint64(&cache.blockHits),
+		BlockMisses:   atomic.LoadUint64(&cache.blockMisses),
+		Accounts:      cache.accounts.Len(),
+		AccountHits:   atomic.LoadUint64(&cache.accountHits),
+		AccountMisses: atomic.LoadUint64(&cache.accountMisses),
+	}
+}
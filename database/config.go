@@ -1,6 +1,18 @@
 package database
 
+// Used when BootstrapWeightMaxBlocks isn't set, roughly the size of the ledger weights.json was taken from
+const DEFAULT_BOOTSTRAP_WEIGHT_MAX_BLOCKS = 1000000
+
 type Config struct {
 	DataDir string
 	Backend string
+
+	BootstrapWeightMaxBlocks uint64 // Voting weights come from weights.json until the ledger has this many blocks, DEFAULT_BOOTSTRAP_WEIGHT_MAX_BLOCKS when 0
+
+	Pruning         bool   // Discard old cemented blocks, frontiers, confirmation heights and receivables are always kept
+	PruningDepth    uint64 // Cemented blocks kept at the top of each account's chain, at least 1
+	PruningInterval uint64 // Seconds between pruning passes
+
+	BlockCacheSize   uint64 // Blocks kept in memory in front of the backend, 0 disables the cache
+	AccountCacheSize uint64 // Accounts kept in memory in front of the backend, 0 disables the cache
 }
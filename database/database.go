@@ -3,6 +3,7 @@ package database
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"log"
 	"os"
 	"path"
@@ -10,7 +11,10 @@ import (
 
 	"github.com/shryder/ed25519-blake2b"
 
+	badger_backend "github.com/Shryder/gnano/database/badger"
 	json_backend "github.com/Shryder/gnano/database/json"
+	"github.com/Shryder/gnano/database/ledger"
+	memory_backend "github.com/Shryder/gnano/database/memory"
 	"github.com/Shryder/gnano/types"
 )
 
@@ -20,16 +24,39 @@ type DatabaseBackend interface {
 	AddNodeIPs(address []string) error
 	GetNodeIPs() (map[string]uint, error)
 
-	GetVotingWeight(address *types.Address) types.Amount
+	GetVotingWeight(address *types.Address) types.Amount // Weight delegated to the representative by the accounts in the ledger
+	ForEachVotingWeight(callback func(address *types.Address, weight types.Amount) error) error
+	GetBootstrapWeight(address *types.Address) types.Amount
+	PutBootstrapWeight(address *types.Address, weight types.Amount) error
+	ForEachBootstrapWeight(callback func(address *types.Address, weight types.Amount) error) error
 
 	PutBlock(block *types.Block) error
+	PutBlocks(blocks []*types.Block) error // Stores all of the blocks or none of them, in order. At most ledger.MAX_PUT_BLOCKS per call
 	GetBlock(hash *types.Hash) *types.Block
+	GetBlockSideband(hash *types.Hash) *types.Sideband                   // Height, successor, account, balance, timestamp and epoch of a stored block
+	GetBlockAtHeight(address *types.Address, height uint64) *types.Block // The open block is at height 1
 	GetBlockCount() uint64
 
+	GetConfirmationHeight(address *types.Address) *ledger.ConfirmationHeight
+	GetCementedCount() uint64
+	CementBlock(hash *types.Hash) ([]*types.Hash, error) // Cements hash, its uncemented ancestors and the sends they receive. Returns the newly cemented hashes in order
+
+	PruneAccount(address *types.Address, depth uint64) (uint64, error) // Discards the cemented blocks more than depth blocks below the confirmation height, returns how many were discarded
+	IsBlockPruned(hash *types.Hash) bool
+	GetPrunedCount() uint64
+
 	GetAccount(address *types.Address) *types.Account
 	GetAccountChain(address *types.Address) []string
 	GetRandomAccountAddress() *types.Address
 	GetAccountCount() uint64
+	ForEachAccount(callback func(address *types.Address) error) error
+
+	GetReceivable(destination *types.Address, sendHash *types.Hash) *ledger.ReceivableInfo
+	GetReceivables(destination *types.Address) map[types.Hash]ledger.ReceivableInfo // send_hash => receivable
+
+	PutUnchecked(entries []*ledger.UncheckedInfo) error
+	DeleteUnchecked(hashes []types.Hash) error
+	ForEachUnchecked(callback func(entry *ledger.UncheckedInfo) error) error
 
 	Cleanup() error
 }
@@ -37,6 +64,11 @@ type DatabaseBackend interface {
 type Database struct {
 	Backend DatabaseBackend
 	Config  *Config
+
+	InitialWeights map[string]types.Amount // Seeds new ledgers instead of weights.json when set
+
+	StopPruning    chan bool // Closed on Cleanup to stop PeriodicPruning
+	PruningStopped chan bool // Closed by PeriodicPruning once it returns
 }
 
 func New(cfg *Config) *Database {
@@ -46,17 +78,55 @@ func New(cfg *Config) *Database {
 }
 
 func (db *Database) InitializeBackend() (DatabaseBackend, error) {
-	switch strings.ToLower(db.Config.Backend) {
-	// case "badger":
-	// 	return badger_backend.Initialize(path.Join(db.Config.DataDir, "Badger"))
+	return db.OpenBackend(db.Config.Backend)
+}
+
+// Opens the backend with the provided name inside the configured DataDir and upgrades its ledger to SCHEMA_VERSION
+func (db *Database) OpenBackend(name string) (DatabaseBackend, error) {
+	initialWeights := db.InitialWeights
+	if initialWeights == nil {
+		var err error
+		initialWeights, err = LoadInitialWeights()
+		if err != nil {
+			return nil, fmt.Errorf("Error loading initial weights: %w", err)
+		}
+	}
+
+	switch strings.ToLower(name) {
+	case "memory":
+		// Nothing is persisted, so the ledger is always new
+		backend := memory_backend.New(initialWeights)
+		backend.Data.Version = SCHEMA_VERSION
+
+		return backend, nil
+	case "badger":
+		backend, err := badger_backend.Initialize(path.Join(db.Config.DataDir, "Badger"), initialWeights)
+		if err != nil {
+			return nil, err
+		}
+
+		err = upgradeBadgerSchema(backend)
+		if err != nil {
+			backend.Cleanup()
+			return nil, err
+		}
+
+		return backend, nil
 	case "json":
-		return json_backend.Initialize(path.Join(db.Config.DataDir, "JSON", "database.json"))
+		return json_backend.Initialize(path.Join(db.Config.DataDir, "JSON", "database.json"), initialWeights, upgradeMemorySchema)
 	}
 
 	return nil, errors.New("Invalid backend provided")
 }
 
 func (srv *Database) LoadOrCreateNodeIdentity() (ed25519.PublicKey, ed25519.PrivateKey, error) {
+	if len(srv.Config.DataDir) == 0 {
+		// Only allowed with the memory backend, nothing can be persisted
+		log.Println("No DataDir provided, using a temporary Node Identity")
+
+		return ed25519.GenerateKey(nil)
+	}
+
 	path := path.Join(srv.Config.DataDir, "node_id.dat")
 	log.Println("Loading Node Identity from", path)
 
@@ -98,22 +168,85 @@ func (srv *Database) LoadOrCreateNodeIdentity() (ed25519.PublicKey, ed25519.Priv
 }
 
 func (db *Database) ValidateAndStart() error {
-	if len(db.Config.DataDir) == 0 {
+	// The memory backend doesn't store anything on disk
+	if len(db.Config.DataDir) == 0 && strings.ToLower(db.Config.Backend) != "memory" {
 		return errors.New("invalid DataDir provided")
 	}
 
-	backend, err := db.InitializeBackend()
+	err := db.validatePruningConfig()
+	if err != nil {
+		return err
+	}
+
+	db.Backend, err = db.InitializeBackend()
 	if err != nil {
 		return err
 	}
 
-	db.Backend = backend
+	if db.Config.BlockCacheSize > 0 || db.Config.AccountCacheSize > 0 {
+		log.Println("Caching up to", db.Config.BlockCacheSize, "blocks and", db.Config.AccountCacheSize, "accounts in memory")
+
+		db.Backend = NewCachedBackend(db.Backend, db.Config.BlockCacheSize, db.Config.AccountCacheSize)
+	}
 
-	log.Println("Block Count:", db.Backend.GetBlockCount())
+	log.Println("Block Count:", db.Backend.GetBlockCount(), "Cemented Count:", db.Backend.GetCementedCount(), "Pruned Count:", db.Backend.GetPrunedCount())
+	if db.IsUsingBootstrapWeights() {
+		log.Println("Using bootstrap weights until the ledger reaches", db.bootstrapWeightMaxBlocks(), "blocks")
+	}
+
+	if db.Config.Pruning {
+		log.Println("Pruning cemented blocks more than", db.Config.PruningDepth, "blocks deep every", db.Config.PruningInterval, "seconds")
+
+		db.StopPruning = make(chan bool)
+		db.PruningStopped = make(chan bool)
+		go db.PeriodicPruning()
+	}
 
 	return nil
 }
 
+// Weights seeded from weights.json are used until the ledger holds BootstrapWeightMaxBlocks blocks,
+// before that the weights derived from a partially synced ledger can't be trusted. Pruned blocks count as part of the ledger.
+func (db *Database) IsUsingBootstrapWeights() bool {
+	return db.Backend.GetBlockCount()+db.Backend.GetPrunedCount() < db.bootstrapWeightMaxBlocks()
+}
+
+func (db *Database) bootstrapWeightMaxBlocks() uint64 {
+	if db.Config.BootstrapWeightMaxBlocks == 0 {
+		return DEFAULT_BOOTSTRAP_WEIGHT_MAX_BLOCKS
+	}
+
+	return db.Config.BootstrapWeightMaxBlocks
+}
+
+func (db *Database) GetVotingWeight(address *types.Address) types.Amount {
+	if db.IsUsingBootstrapWeights() {
+		return db.Backend.GetBootstrapWeight(address)
+	}
+
+	return db.Backend.GetVotingWeight(address)
+}
+
+// Whether the block is part of the ledger, either stored or pruned after it was cemented
+func (db *Database) HasBlock(hash *types.Hash) bool {
+	return db.Backend.GetBlock(hash) != nil || db.Backend.IsBlockPruned(hash)
+}
+
+// Whether the block is stored and at or below its account's confirmation height, pruned blocks were all cemented
+func (db *Database) IsBlockCemented(hash *types.Hash) bool {
+	sideband := db.Backend.GetBlockSideband(hash)
+	if sideband == nil {
+		return db.Backend.IsBlockPruned(hash)
+	}
+
+	return ledger.IsCemented(sideband, db.Backend.GetConfirmationHeight(sideband.Account))
+}
+
 func (db *Database) Cleanup() error {
+	if db.StopPruning != nil {
+		close(db.StopPruning)
+		<-db.PruningStopped
+	}
+
 	return db.Backend.Cleanup()
 }
@@ -0,0 +1,277 @@
+package journal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/Shryder/gnano/types"
+)
+
+// Blocks are a few hundred bytes once marshalled, anything bigger than this is a damaged length field
+const MAX_RECORD_SIZE = 1 << 20
+
+// A journaled ledger write, exactly one of the fields is set
+type Record struct {
+	Block  *types.Block `json:"block,omitempty"`  // Block added to the ledger
+	Cement *types.Hash  `json:"cement,omitempty"` // Block cemented along with its dependencies
+}
+
+// Append-only write-ahead log of blocks added to the ledger and blocks cemented.
+// Every record is laid out as [4 bytes length][4 bytes crc32 of the payload][record JSON], all big endian.
+// A backend appends each write before acknowledging it, replays the journal on startup and drops it once a snapshot containing its writes has been written.
+type Journal struct {
+	Path string
+
+	file  *os.File
+	mutex sync.Mutex
+}
+
+// Path of the journal that was rotated out and is waiting for the snapshot that includes it
+func (journal *Journal) RotatedPath() string {
+	return journal.Path + ".old"
+}
+
+func Open(path string) (*Journal, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Journal{
+		Path: path,
+		file: file,
+	}, nil
+}
+
+func encodeRecord(record *Record) ([]byte, error) {
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded := make([]byte, 8, 8+len(payload))
+	binary.BigEndian.PutUint32(encoded[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(encoded[4:8], crc32.ChecksumIEEE(payload))
+
+	return append(encoded, payload...), nil
+}
+
+// Appends blocks to the journal and fsyncs it, blocks are durable once this returns
+func (journal *Journal) Append(blocks ...*types.Block) error {
+	records := make([]*Record, len(blocks))
+	for i, block := range blocks {
+		records[i] = &Record{Block: block}
+	}
+
+	return journal.write(records)
+}
+
+// Journals that hash was cemented, durable once this returns
+func (journal *Journal) AppendCement(hash *types.Hash) error {
+	return journal.write([]*Record{{Cement: hash}})
+}
+
+func (journal *Journal) write(records []*Record) error {
+	encoded := make([]byte, 0)
+	for _, record := range records {
+		encoded_record, err := encodeRecord(record)
+		if err != nil {
+			return err
+		}
+
+		encoded = append(encoded, encoded_record...)
+	}
+
+	journal.mutex.Lock()
+	defer journal.mutex.Unlock()
+
+	_, err := journal.file.Write(encoded)
+	if err != nil {
+		return err
+	}
+
+	return journal.file.Sync()
+}
+
+// Moves the current records out of the way so that a snapshot can be taken, new appends go to a fresh journal.
+// Must be called while no block can be appended, i.e. under the same lock the snapshot is taken with.
+func (journal *Journal) Rotate() error {
+	journal.mutex.Lock()
+	defer journal.mutex.Unlock()
+
+	stat, err := journal.file.Stat()
+	if err != nil {
+		return err
+	}
+
+	if stat.Size() == 0 {
+		// Nothing was journaled since the last rotation
+		return nil
+	}
+
+	_, err = os.Stat(journal.RotatedPath())
+	if err == nil {
+		// The previous snapshot failed and its records were never dropped, keep them and add the new ones after them
+		return journal.appendToRotated()
+	}
+
+	if !os.IsNotExist(err) {
+		return err
+	}
+
+	err = journal.file.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(journal.Path, journal.RotatedPath())
+	if err != nil {
+		return err
+	}
+
+	journal.file, err = os.OpenFile(journal.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND|os.O_TRUNC, 0600)
+
+	return err
+}
+
+func (journal *Journal) appendToRotated() error {
+	current, err := os.ReadFile(journal.Path)
+	if err != nil {
+		return err
+	}
+
+	rotated, err := os.OpenFile(journal.RotatedPath(), os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+
+	defer rotated.Close()
+
+	_, err = rotated.Write(current)
+	if err != nil {
+		return err
+	}
+
+	err = rotated.Sync()
+	if err != nil {
+		return err
+	}
+
+	return journal.file.Truncate(0)
+}
+
+// Drops the rotated records, called once they are part of a snapshot
+func (journal *Journal) RemoveRotated() error {
+	err := os.Remove(journal.RotatedPath())
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+func (journal *Journal) Close() error {
+	journal.mutex.Lock()
+	defer journal.mutex.Unlock()
+
+	return journal.file.Close()
+}
+
+// Calls callback for every record in the rotated journal and then the current one, in the order they were appended.
+// A torn or corrupt record (e.g. power loss mid-append) ends the replay of that file and is cut off so new appends don't land after garbage.
+func (journal *Journal) Replay(callback func(record *Record) error) (uint, error) {
+	count := uint(0)
+	for _, path := range []string{journal.RotatedPath(), journal.Path} {
+		replayed, err := replayFile(path, callback)
+		count += replayed
+		if err != nil {
+			return count, err
+		}
+	}
+
+	return count, nil
+}
+
+func replayFile(path string, callback func(record *Record) error) (uint, error) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0600)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	count := uint(0)
+	offset := int64(0)
+	for {
+		record, size, err := readRecord(reader)
+		if err == io.EOF {
+			return count, nil
+		}
+
+		if err != nil {
+			log.Println("Truncating journal", path, "at offset", offset, "because of a damaged record:", err)
+
+			return count, file.Truncate(offset)
+		}
+
+		err = callback(record)
+		if err != nil {
+			return count, fmt.Errorf("error replaying record at offset %d of journal %s: %w", offset, path, err)
+		}
+
+		offset += size
+		count++
+	}
+}
+
+func readRecord(reader *bufio.Reader) (*Record, int64, error) {
+	header := make([]byte, 8)
+	n, err := io.ReadFull(reader, header)
+	if err == io.EOF {
+		return nil, 0, io.EOF
+	}
+
+	if err != nil {
+		return nil, 0, fmt.Errorf("incomplete header (%d bytes): %w", n, err)
+	}
+
+	size := binary.BigEndian.Uint32(header[0:4])
+	if size > MAX_RECORD_SIZE {
+		return nil, 0, fmt.Errorf("record size %d exceeds the maximum of %d bytes", size, MAX_RECORD_SIZE)
+	}
+
+	payload := make([]byte, size)
+	_, err = io.ReadFull(reader, payload)
+	if err != nil {
+		return nil, 0, fmt.Errorf("incomplete payload: %w", err)
+	}
+
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:8]) {
+		return nil, 0, errors.New("checksum mismatch")
+	}
+
+	var record Record
+	err = json.Unmarshal(payload, &record)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if (record.Block == nil) == (record.Cement == nil) {
+		return nil, 0, errors.New("record must contain either a block or a cement")
+	}
+
+	return &record, int64(len(header) + len(payload)), nil
+}
@@ -0,0 +1,205 @@
+package journal
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Shryder/gnano/types"
+)
+
+func testBlock(hash byte) *types.Block {
+	return &types.Block{Type: types.BLOCK_TYPE_STATE, Hash: &types.Hash{hash}}
+}
+
+func openTestJournal(t *testing.T) *Journal {
+	journal, err := Open(filepath.Join(t.TempDir(), "journal"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { journal.Close() })
+
+	return journal
+}
+
+// Hashes of the replayed blocks and cements, in order
+func replayed(t *testing.T, journal *Journal) []types.Hash {
+	hashes := make([]types.Hash, 0)
+	_, err := journal.Replay(func(record *Record) error {
+		if record.Block != nil {
+			hashes = append(hashes, *record.Block.Hash)
+		} else {
+			hashes = append(hashes, *record.Cement)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		t.Fatal("replay failed:", err)
+	}
+
+	return hashes
+}
+
+func expectReplayed(t *testing.T, journal *Journal, want ...byte) {
+	t.Helper()
+
+	hashes := replayed(t, journal)
+	if len(hashes) != len(want) {
+		t.Fatalf("replayed %d records, want %d", len(hashes), len(want))
+	}
+
+	for i, hash := range hashes {
+		if hash != (types.Hash{want[i]}) {
+			t.Errorf("record %d is for %s, want %s", i, hash.ToHexString(), (&types.Hash{want[i]}).ToHexString())
+		}
+	}
+}
+
+func appendRaw(t *testing.T, path string, data []byte) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer file.Close()
+
+	_, err = file.Write(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAppendAndReplay(t *testing.T) {
+	journal := openTestJournal(t)
+
+	err := journal.Append(testBlock(0x01), testBlock(0x02))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = journal.AppendCement(&types.Hash{0x02})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records := make([]*Record, 0)
+	count, err := journal.Replay(func(record *Record) error {
+		records = append(records, record)
+		return nil
+	})
+
+	if err != nil || count != 3 {
+		t.Fatalf("replayed %d records with error %v, want 3", count, err)
+	}
+
+	if records[0].Block == nil || records[1].Block == nil || records[2].Cement == nil {
+		t.Errorf("replayed %+v, want two blocks and a cement", records)
+	}
+}
+
+func TestReplayTruncatesTornRecord(t *testing.T) {
+	journal := openTestJournal(t)
+
+	err := journal.Append(testBlock(0x01))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stat, err := os.Stat(journal.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Power loss halfway through appending the second block
+	encoded, err := encodeRecord(&Record{Block: testBlock(0x02)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	appendRaw(t, journal.Path, encoded[:len(encoded)/2])
+
+	expectReplayed(t, journal, 0x01)
+
+	truncated, err := os.Stat(journal.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if truncated.Size() != stat.Size() {
+		t.Errorf("journal is %d bytes after the replay, want it cut back to %d", truncated.Size(), stat.Size())
+	}
+
+	// Appends after the truncation are read back instead of landing behind the torn record
+	err = journal.Append(testBlock(0x03))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectReplayed(t, journal, 0x01, 0x03)
+}
+
+func TestReplayRejectsOversizedRecord(t *testing.T) {
+	journal := openTestJournal(t)
+
+	err := journal.Append(testBlock(0x01))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Damaged length field, the payload it claims isn't even read
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header[0:4], MAX_RECORD_SIZE+1)
+	appendRaw(t, journal.Path, header)
+
+	expectReplayed(t, journal, 0x01)
+}
+
+func TestRotate(t *testing.T) {
+	journal := openTestJournal(t)
+
+	err := journal.Append(testBlock(0x01))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = journal.Rotate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = journal.Append(testBlock(0x02))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectReplayed(t, journal, 0x01, 0x02)
+
+	// The snapshot of the first rotation failed, so .old is still there when rotating again
+	err = journal.Rotate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stat, err := os.Stat(journal.Path)
+	if err != nil || stat.Size() != 0 {
+		t.Fatalf("current journal wasn't emptied by the rotation (error %v)", err)
+	}
+
+	err = journal.Append(testBlock(0x03))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectReplayed(t, journal, 0x01, 0x02, 0x03)
+
+	err = journal.RemoveRotated()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectReplayed(t, journal, 0x03)
+}
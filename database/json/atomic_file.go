@@ -0,0 +1,52 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Writes data to a temporary file next to path, fsyncs it and renames it over path.
+// Readers (and a node restarting after a crash) either see the previous file or the new one, never a torn write.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	// No-op once the rename succeeded
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	err = tmp.Sync()
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return err
+	}
+
+	// Persist the rename itself
+	dirFile, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+
+	defer dirFile.Close()
+
+	return dirFile.Sync()
+}
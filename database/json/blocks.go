@@ -2,76 +2,79 @@ package database
 
 import (
 	"fmt"
-	"math/big"
-	"time"
+	"log"
 
+	"github.com/Shryder/gnano/database/journal"
 	"github.com/Shryder/gnano/types"
 )
 
-func (backend *JSONBackend) GetBlockCount() uint64 {
-	backend.DataMutex.RLock()
-	defer backend.DataMutex.RUnlock()
-
-	return uint64(len(backend.Data.Blocks))
+func (backend *JSONBackend) PutBlock(block *types.Block) error {
+	return backend.PutBlocks([]*types.Block{block})
 }
 
-func (backend *JSONBackend) GetBlock(hash *types.Hash) *types.Block {
-	backend.DataMutex.RLock()
-	defer backend.DataMutex.RUnlock()
+func (backend *JSONBackend) PutBlocks(blocks []*types.Block) error {
+	backend.DataMutex.Lock()
+	defer backend.DataMutex.Unlock()
 
-	block, found := backend.Data.Blocks[hash.ToHexString()]
-	if !found {
-		return nil
+	staged, err := backend.StageBlocks(blocks)
+	if err != nil {
+		return err
+	}
+
+	// Blocks have to survive a crash before the next snapshot, so they are journaled before being acknowledged
+	err = backend.Journal.Append(blocks...)
+	if err != nil {
+		return fmt.Errorf("error journaling %d blocks: %w", len(blocks), err)
 	}
 
-	return &block
+	staged.Apply()
+
+	return nil
 }
 
-// Update local account ledger entry. Contains height, timestamp and frontier
-func (backend *JSONBackend) UpdateLocalAccount(block *types.Block) error {
-	blockAccountHex := block.Account.ToHexString()
+// Re-applies the blocks and cementings that were journaled after the last snapshot was written
+func (backend *JSONBackend) replayJournal() error {
+	replayed, err := backend.Journal.Replay(func(record *journal.Record) error {
+		if record.Cement != nil {
+			if backend.Data.Pruned[record.Cement.ToHexString()] {
+				// Pruned after being cemented, the snapshot holds its confirmation height
+				return nil
+			}
 
-	account, ok := backend.Data.Accounts[blockAccountHex]
-	if !ok {
-		if !block.IsOpenBlock() {
-			return fmt.Errorf("error inserting block %s into the ledger because an OPEN block was expected", block.Hash.ToHexString())
-		}
+			// Already cemented blocks are skipped by ledger.Cement
+			cementing, err := backend.PlanCementing(record.Cement)
+			if err != nil {
+				return err
+			}
+
+			backend.ApplyCementing(cementing)
 
-		// Initialize account in ledger
-		backend.Data.Accounts[blockAccountHex] = DBAccount{
-			Frontier: block.Hash,
-			Sideband: &types.Sideband{
-				Height:    big.NewInt(1),
-				Timestamp: uint(time.Now().Unix()),
-			},
+			return nil
 		}
-	} else {
-		if block.Previous.Cmp(account.Frontier) != 0 {
-			return fmt.Errorf("error inserting block %s into the ledger because current frontier block is %s but this block's previous is %s", block.Hash.ToHexString(), account.Frontier.ToHexString(), block.Previous.ToHexString())
+
+		hash := record.Block.Hash.ToHexString()
+		if _, found := backend.Data.Blocks[hash]; found || backend.Data.Pruned[hash] {
+			// Already part of the snapshot, or was and has been pruned since
+			return nil
 		}
 
-		backend.Data.Accounts[blockAccountHex] = DBAccount{
-			Frontier: block.Hash,
-			Sideband: &types.Sideband{
-				Height:    account.Sideband.Height.Add(account.Sideband.Height, big.NewInt(1)), // Increase height by 1
-				Timestamp: account.Sideband.Timestamp,
-			},
+		staged, err := backend.StageBlocks([]*types.Block{record.Block})
+		if err != nil {
+			return err
 		}
-	}
 
-	return nil
-}
+		staged.Apply()
 
-func (backend *JSONBackend) PutBlock(block *types.Block) error {
-	backend.DataMutex.Lock()
-	defer backend.DataMutex.Unlock()
+		return nil
+	})
 
-	err := backend.UpdateLocalAccount(block)
 	if err != nil {
 		return err
 	}
 
-	backend.Data.Blocks[block.Hash.ToHexString()] = *block
+	if replayed > 0 {
+		log.Println("Replayed", replayed, "records from the journal")
+	}
 
 	return nil
 }
@@ -0,0 +1,30 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/Shryder/gnano/types"
+)
+
+func (backend *JSONBackend) CementBlock(hash *types.Hash) ([]*types.Hash, error) {
+	backend.DataMutex.Lock()
+	defer backend.DataMutex.Unlock()
+
+	cementing, err := backend.PlanCementing(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cementing.Blocks) == 0 {
+		return cementing.Blocks, nil
+	}
+
+	err = backend.Journal.AppendCement(hash)
+	if err != nil {
+		return nil, fmt.Errorf("error journaling cementing of %s: %w", hash.ToHexString(), err)
+	}
+
+	backend.ApplyCementing(cementing)
+
+	return cementing.Blocks, nil
+}
@@ -6,69 +6,45 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"path"
 	"path/filepath"
-	"sync"
 	"time"
 
+	"github.com/Shryder/gnano/database/journal"
+	memory_backend "github.com/Shryder/gnano/database/memory"
 	"github.com/Shryder/gnano/types"
 )
 
-type DBAccount struct {
-	Frontier *types.Hash     `json:"frontier"`
-	Sideband *types.Sideband `json:"sideband"`
-}
-
-type DBSchema struct {
-	Nodes        map[string]uint         `json:"nodes"`    // ip => discovery_timestamp
-	Blocks       map[string]types.Block  `json:"blocks"`   // hash => block
-	Accounts     map[string]DBAccount    `json:"accounts"` // public_key => account
-	VotingWeight map[string]types.Amount `json:"weights"`  // public_key => weight
-}
-
+// The in-memory ledger, snapshotted to a JSON file every few seconds with a journal covering the writes in between
 type JSONBackend struct {
+	*memory_backend.MemoryBackend
+
 	FilePath string
 
-	Data      DBSchema
-	DataMutex sync.RWMutex
+	Journal *journal.Journal // Blocks added and cemented since the last snapshot
 
-	Closed bool
+	StopChannel  chan bool // Closed on Cleanup to stop PeriodicSaves
+	SaverStopped chan bool // Closed by PeriodicSaves once it returns
 }
 
 func (backend *JSONBackend) BackendName() string {
 	return "JSON"
 }
 
+// Stops the periodic saver and flushes whatever was written since the last snapshot
 func (backend *JSONBackend) Cleanup() error {
-	backend.Closed = true
-
-	return nil
-}
-
-func loadInitialWeights() (map[string]types.Amount, error) {
-	cwd, err := os.Getwd()
-	if err != nil {
-		return nil, err
-	}
-
-	path := path.Join(cwd, "weights.json")
-
-	contents, err := os.ReadFile(path)
-	if err != nil {
-		return nil, err
-	}
+	close(backend.StopChannel)
+	<-backend.SaverStopped
 
-	initialWeightsRaw := make(map[string]types.Amount)
-	err = json.Unmarshal(contents, &initialWeightsRaw)
+	err := backend.Save()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return initialWeightsRaw, nil
+	return backend.Journal.Close()
 }
 
-func loadOrCreateLedgerDB(path string) (*DBSchema, error) {
-	var data DBSchema
+func loadOrCreateLedgerDB(path string, initialWeights map[string]types.Amount) (*memory_backend.DBSchema, error) {
+	var data memory_backend.DBSchema
 	stat, err := os.Stat(path)
 	if err != nil {
 		if !os.IsNotExist(err) {
@@ -80,25 +56,8 @@ func loadOrCreateLedgerDB(path string) (*DBSchema, error) {
 			return nil, err
 		}
 
-		file, err := os.Create(path)
-		if err != nil {
-			return nil, err
-		}
-
 		// Fill with default empty values
-		data = DBSchema{
-			Nodes:        make(map[string]uint),
-			Blocks:       make(map[string]types.Block),
-			Accounts:     make(map[string]DBAccount),
-			VotingWeight: make(map[string]types.Amount),
-		}
-
-		initialWeights, err := loadInitialWeights()
-		if err != nil {
-			return nil, fmt.Errorf("Error loading initial weights: %w", err)
-		}
-
-		data.VotingWeight = initialWeights
+		data = memory_backend.NewSchema(initialWeights)
 
 		defaultData, err := json.Marshal(data)
 		if err != nil {
@@ -106,7 +65,7 @@ func loadOrCreateLedgerDB(path string) (*DBSchema, error) {
 		}
 
 		// Write empty object
-		_, err = file.Write(defaultData)
+		err = writeFileAtomic(path, defaultData)
 		if err != nil {
 			return nil, err
 		}
@@ -129,17 +88,46 @@ func loadOrCreateLedgerDB(path string) (*DBSchema, error) {
 	return &data, nil
 }
 
-func Initialize(path string) (*JSONBackend, error) {
+// upgrade brings the snapshot to the current schema version, it runs before the journal is replayed on top of it
+func Initialize(path string, initialWeights map[string]types.Amount, upgrade func(data *memory_backend.DBSchema) error) (*JSONBackend, error) {
 	log.Println("Loading JSON backend from", path)
 
-	data, err := loadOrCreateLedgerDB(path)
+	data, err := loadOrCreateLedgerDB(path, initialWeights)
+	if err != nil {
+		return nil, err
+	}
+
+	err = upgrade(data)
+	if err != nil {
+		return nil, err
+	}
+
+	ledger_journal, err := journal.Open(path + ".journal")
 	if err != nil {
 		return nil, err
 	}
 
 	backend := &JSONBackend{
+		MemoryBackend: &memory_backend.MemoryBackend{Data: *data},
+
 		FilePath: path,
-		Data:     *data,
+		Journal:  ledger_journal,
+
+		StopChannel:  make(chan bool),
+		SaverStopped: make(chan bool),
+	}
+
+	err = backend.replayJournal()
+	if err != nil {
+		ledger_journal.Close()
+		return nil, err
+	}
+
+	// Compact the replayed blocks into the snapshot right away
+	err = backend.Save()
+	if err != nil {
+		ledger_journal.Close()
+		return nil, err
 	}
 
 	go backend.PeriodicSaves()
@@ -147,24 +135,43 @@ func Initialize(path string) (*JSONBackend, error) {
 	return backend, nil
 }
 
-func (backend *JSONBackend) PeriodicSaves() {
-	for {
-		if backend.Closed {
-			return
-		}
+// Marshals the ledger under a read lock so that the snapshot is consistent, then atomically replaces the file on disk.
+// The journal is rotated under the same lock and the rotated part is dropped once the snapshot that includes it is on disk.
+func (backend *JSONBackend) Save() error {
+	backend.DataMutex.RLock()
+	jsonified, err := json.Marshal(backend.Data)
+	if err == nil {
+		err = backend.Journal.Rotate()
+	}
+	backend.DataMutex.RUnlock()
 
-		time.Sleep(time.Second * 5)
+	if err != nil {
+		return fmt.Errorf("error taking JSON snapshot: %w", err)
+	}
 
-		jsonified, err := json.Marshal(backend.Data)
-		if err != nil {
-			log.Println("Error marshalling JSON data:", err)
-			continue
-		}
+	err = writeFileAtomic(backend.FilePath, jsonified)
+	if err != nil {
+		return fmt.Errorf("error writing JSON database to file: %w", err)
+	}
 
-		err = os.WriteFile(backend.FilePath, jsonified, os.ModeAppend)
-		if err != nil {
-			log.Println("Error writing JSON database to file:", err)
-			continue
+	return backend.Journal.RemoveRotated()
+}
+
+func (backend *JSONBackend) PeriodicSaves() {
+	defer close(backend.SaverStopped)
+
+	ticker := time.NewTicker(time.Second * 5)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-backend.StopChannel:
+			return
+		case <-ticker.C:
+			err := backend.Save()
+			if err != nil {
+				log.Println(err)
+			}
 		}
 	}
 }
@@ -0,0 +1,30 @@
+package ledger
+
+import (
+	"github.com/Shryder/gnano/types"
+)
+
+// Per account entry stored by every backend. Contains the frontier hash, the current representative and the frontier's sideband (height, balance, timestamp)
+type AccountInfo struct {
+	Frontier       *types.Hash     `json:"frontier"`
+	Representative *types.Address  `json:"representative"`
+	Sideband       *types.Sideband `json:"sideband"`
+}
+
+// Balance of the account, an account that wasn't opened yet has none
+func (info *AccountInfo) Balance() types.Amount {
+	if info == nil || info.Sideband == nil {
+		return types.Amount{}
+	}
+
+	return info.Sideband.Balance
+}
+
+// Epoch of the account's frontier, accounts that weren't opened yet are at epoch 0
+func (info *AccountInfo) Epoch() byte {
+	if info == nil || info.Sideband == nil {
+		return types.EPOCH_0
+	}
+
+	return info.Sideband.Epoch
+}
@@ -0,0 +1,214 @@
+package ledger
+
+import (
+	"fmt"
+
+	"github.com/Shryder/gnano/types"
+)
+
+// Highest cemented block of an account, blocks above it are stored but not confirmed yet
+type ConfirmationHeight struct {
+	Height   uint64     `json:"height"`
+	Frontier types.Hash `json:"frontier"`
+}
+
+// Read access to the ledger that cementing needs, missing entries are returned as nil without an error
+type CementView interface {
+	GetBlock(hash *types.Hash) (*types.Block, error)
+	GetBlockSideband(hash *types.Hash) (*types.Sideband, error)
+	GetConfirmationHeight(address *types.Address) (*ConfirmationHeight, error)
+	IsBlockPruned(hash *types.Hash) (bool, error)
+}
+
+// Confirmation heights a backend has to write to cement a block
+type Cementing struct {
+	Heights map[types.Address]ConfirmationHeight
+	Blocks  []*types.Hash // Newly cemented blocks, in the order they were cemented
+}
+
+type cementEntry struct {
+	block    *types.Block
+	sideband *types.Sideband
+}
+
+func (cementing *Cementing) height(view CementView, account types.Address) (uint64, error) {
+	if confirmation_height, ok := cementing.Heights[account]; ok {
+		return confirmation_height.Height, nil
+	}
+
+	confirmation_height, err := view.GetConfirmationHeight(&account)
+	if err != nil || confirmation_height == nil {
+		return 0, err
+	}
+
+	return confirmation_height.Height, nil
+}
+
+func getSideband(view CementView, hash *types.Hash) (*types.Sideband, error) {
+	sideband, err := view.GetBlockSideband(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if sideband == nil {
+		return nil, fmt.Errorf("%w: %s", ErrBlockNotFound, hash.ToHexString())
+	}
+
+	return sideband, nil
+}
+
+func (cementing *Cementing) isCemented(view CementView, hash *types.Hash) (bool, error) {
+	sideband, err := view.GetBlockSideband(hash)
+	if err != nil {
+		return false, err
+	}
+
+	if sideband == nil {
+		// Only cemented blocks are pruned
+		pruned, err := view.IsBlockPruned(hash)
+		if err != nil || pruned {
+			return pruned, err
+		}
+
+		return false, fmt.Errorf("%w: %s", ErrBlockNotFound, hash.ToHexString())
+	}
+
+	height, err := cementing.height(view, *sideband.Account)
+	if err != nil {
+		return false, err
+	}
+
+	return sideband.Height.Uint64() <= height, nil
+}
+
+// Blocks between the account's confirmation height (exclusive) and hash (inclusive), ordered from lowest height
+func (cementing *Cementing) uncementedSegment(view CementView, hash *types.Hash) ([]cementEntry, error) {
+	sideband, err := getSideband(view, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	cemented_height, err := cementing.height(view, *sideband.Account)
+	if err != nil {
+		return nil, err
+	}
+
+	if sideband.Height.Uint64() <= cemented_height {
+		return nil, nil
+	}
+
+	segment := make([]cementEntry, sideband.Height.Uint64()-cemented_height)
+	cursor := hash
+	for i := len(segment) - 1; i >= 0; i-- {
+		block, err := view.GetBlock(cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		if block == nil {
+			return nil, fmt.Errorf("%w: %s", ErrBlockNotFound, cursor.ToHexString())
+		}
+
+		sideband, err := getSideband(view, cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		segment[i] = cementEntry{block: block, sideband: sideband}
+		cursor = block.Previous
+	}
+
+	return segment, nil
+}
+
+// Send that the block receives, nil if it isn't a receive
+func receiveSource(view CementView, entry cementEntry) (*types.Hash, error) {
+	block := entry.block
+	if block.Link == nil || isGenesisOpen(block) {
+		return nil, nil
+	}
+
+	switch block.Type {
+	case types.BLOCK_TYPE_OPEN, types.BLOCK_TYPE_RECEIVE:
+		source := types.Hash(*block.Link)
+
+		return &source, nil
+	case types.BLOCK_TYPE_STATE:
+		previous_balance := types.Amount{}
+		if !block.IsOpenBlock() {
+			previous, err := getSideband(view, block.Previous)
+			if err != nil {
+				return nil, err
+			}
+
+			previous_balance = previous.Balance
+		}
+
+		if entry.sideband.Balance.Cmp(previous_balance) > 0 {
+			source := types.Hash(*block.Link)
+
+			return &source, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// Works out the confirmation heights to write to cement hash along with its uncemented ancestors.
+// The sends received by those blocks are cemented first, since a receive can't be confirmed before its source.
+func Cement(view CementView, hash *types.Hash) (*Cementing, error) {
+	cementing := &Cementing{
+		Heights: make(map[types.Address]ConfirmationHeight),
+		Blocks:  make([]*types.Hash, 0),
+	}
+
+	pending := []*types.Hash{hash}
+	for len(pending) > 0 {
+		top := pending[len(pending)-1]
+
+		segment, err := cementing.uncementedSegment(view, top)
+		if err != nil {
+			return nil, err
+		}
+
+		blocked := false
+		for _, entry := range segment {
+			source, err := receiveSource(view, entry)
+			if err != nil {
+				return nil, err
+			}
+
+			if source != nil {
+				cemented, err := cementing.isCemented(view, source)
+				if err != nil {
+					return nil, err
+				}
+
+				if !cemented {
+					// Cement the source first and then come back to this block
+					pending = append(pending, source)
+					blocked = true
+					break
+				}
+			}
+
+			cementing.Heights[*entry.sideband.Account] = ConfirmationHeight{
+				Height:   entry.sideband.Height.Uint64(),
+				Frontier: *entry.block.Hash,
+			}
+
+			cementing.Blocks = append(cementing.Blocks, entry.block.Hash)
+		}
+
+		if !blocked {
+			pending = pending[:len(pending)-1]
+		}
+	}
+
+	return cementing, nil
+}
+
+// Whether the block at sideband is at or below the account's confirmation height
+func IsCemented(sideband *types.Sideband, confirmation_height *ConfirmationHeight) bool {
+	return sideband != nil && confirmation_height != nil && sideband.Height.Uint64() <= confirmation_height.Height
+}
@@ -0,0 +1,27 @@
+package ledger
+
+import "github.com/Shryder/gnano/types"
+
+// Epoch blocks are state blocks linking to one of these, the text is left aligned and padded with zeros
+var epochLinks = map[types.Link]byte{
+	epochLink("epoch v1 block"): types.EPOCH_1,
+	epochLink("epoch v2 block"): types.EPOCH_2,
+}
+
+func epochLink(text string) types.Link {
+	var link types.Link
+	copy(link[:], text)
+
+	return link
+}
+
+// Returns the epoch that an epoch block with this link upgrades an account to
+func EpochOfLink(link *types.Link) (byte, bool) {
+	if link == nil {
+		return 0, false
+	}
+
+	epoch, found := epochLinks[*link]
+
+	return epoch, found
+}
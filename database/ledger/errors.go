@@ -0,0 +1,40 @@
+package ledger
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Shryder/gnano/types"
+)
+
+var (
+	ErrOpenBlockExpected   = errors.New("an open block was expected")
+	ErrPreviousNotFrontier = errors.New("block's previous is not the account's frontier")
+	ErrPreviousNotFound    = errors.New("previous block not found")
+	ErrBlockNotFound       = errors.New("block not found")
+	ErrNegativeSpend       = errors.New("send block's balance is higher than the account's balance")
+	ErrUnreceivable        = errors.New("source block is not receivable")
+	ErrBalanceMismatch     = errors.New("received amount doesn't match the send's amount")
+
+	ErrRepresentativeMismatch = errors.New("epoch block doesn't keep the account's representative")
+	ErrBlockPosition          = errors.New("block can't follow the account's frontier")
+	ErrBatchTooLarge          = fmt.Errorf("batches can't hold more than %d blocks", MAX_PUT_BLOCKS)
+)
+
+// Most blocks a single PutBlocks call accepts, so a batch always fits in one badger transaction
+const MAX_PUT_BLOCKS = 1024
+
+// Returned by PutBlock and PutBlocks when a block is rejected. Nothing from the batch is stored when this is returned.
+type BlockError struct {
+	Index int // Position of the rejected block in the batch
+	Hash  *types.Hash
+	Err   error
+}
+
+func (err *BlockError) Error() string {
+	return fmt.Sprintf("error inserting block %s (batch index %d) into the ledger: %s", err.Hash.ToHexString(), err.Index, err.Err.Error())
+}
+
+func (err *BlockError) Unwrap() error {
+	return err.Err
+}
@@ -0,0 +1,18 @@
+package ledger
+
+import "github.com/Shryder/gnano/types"
+
+// The only block that creates funds instead of receiving them, set once from the genesis file before any block is
+// processed. Until then no block is accepted as the genesis block.
+var genesisHash *types.Hash
+
+func SetGenesis(genesis *types.Block) {
+	hash := *genesis.Hash
+
+	genesisHash = &hash
+}
+
+// Opens the genesis account with the whole supply
+func isGenesisOpen(block *types.Block) bool {
+	return genesisHash != nil && block.Hash != nil && *block.Hash == *genesisHash
+}
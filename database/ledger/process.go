@@ -0,0 +1,263 @@
+package ledger
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/Shryder/gnano/types"
+)
+
+// Read access to the ledger that the rules need. Backends must make the writes of blocks earlier in the same batch visible.
+// Missing entries are returned as nil without an error.
+type View interface {
+	GetAccountInfo(address *types.Address) (*AccountInfo, error)
+	GetReceivable(key ReceivableKey) (*ReceivableInfo, error)
+	GetBlockSideband(hash *types.Hash) (*types.Sideband, error)
+	GetBlock(hash *types.Hash) (*types.Block, error)
+}
+
+// Writes a backend has to apply once a block is accepted
+type Changes struct {
+	Account    *AccountInfo
+	NewAccount bool
+
+	Sideband *types.Sideband // Sideband of the block itself, the previous block's successor has to be set to it
+
+	AddReceivable    *Receivable    // Set by sends
+	RemoveReceivable *ReceivableKey // Set by receives
+
+	WeightRemoved *WeightChange // Previous balance taken from the previous representative
+	WeightAdded   *WeightChange // New balance delegated to the current representative
+}
+
+var maxSupply = types.Amount{Hi: ^uint64(0), Lo: ^uint64(0)}
+
+// Legacy send, receive and change blocks don't contain their account, it is taken from the previous block's sideband
+func resolveAccount(view View, block *types.Block) error {
+	if block.Account != nil {
+		return nil
+	}
+
+	previous, err := view.GetBlockSideband(block.Previous)
+	if err != nil {
+		return err
+	}
+
+	if previous == nil {
+		return fmt.Errorf("%w: %s", ErrPreviousNotFound, block.Previous.ToHexString())
+	}
+
+	block.Account = previous.Account
+
+	return nil
+}
+
+// Validates block on top of the current state of its account and returns the writes that accepting it results in.
+// The account of legacy blocks is filled in.
+func ProcessBlock(view View, block *types.Block) (*Changes, error) {
+	err := resolveAccount(view, block)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := view.GetAccountInfo(block.Account)
+	if err != nil {
+		return nil, err
+	}
+
+	if current == nil && !block.IsOpenBlock() {
+		return nil, ErrOpenBlockExpected
+	}
+
+	if current != nil && block.Previous.Cmp(current.Frontier) != 0 {
+		return nil, fmt.Errorf("%w: current frontier block is %s but this block's previous is %s", ErrPreviousNotFrontier, current.Frontier.ToHexString(), block.Previous.ToHexString())
+	}
+
+	err = checkLegacyPosition(view, block, current)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := &Changes{NewAccount: current == nil}
+	previous_balance := current.Balance()
+	balance := previous_balance
+	epoch := current.Epoch()
+
+	switch block.Type {
+	case types.BLOCK_TYPE_SEND:
+		if block.Balance.Cmp(previous_balance) > 0 {
+			return nil, ErrNegativeSpend
+		}
+
+		balance = *block.Balance
+		changes.AddReceivable = newReceivable(block, previous_balance.Sub(balance), epoch)
+	case types.BLOCK_TYPE_OPEN, types.BLOCK_TYPE_RECEIVE:
+		if isGenesisOpen(block) {
+			balance = maxSupply
+			if block.Balance != nil {
+				balance = *block.Balance
+			}
+
+			break
+		}
+
+		receivable, err := getReceivable(view, block)
+		if err != nil {
+			return nil, err
+		}
+
+		balance = previous_balance.Add(receivable.Info.Amount)
+		epoch = maxEpoch(epoch, receivable.Info.Epoch)
+		changes.RemoveReceivable = &receivable.Key
+	case types.BLOCK_TYPE_CHANGE:
+		// Only the representative changes
+	case types.BLOCK_TYPE_STATE:
+		balance = *block.Balance
+
+		// Epoch blocks are signed by the epoch signer instead of the account, all they may change is its epoch
+		if block_epoch, is_epoch := EpochOfLink(block.Link); is_epoch {
+			if balance.Cmp(previous_balance) != 0 {
+				return nil, fmt.Errorf("%w: epoch block changes the balance from %s to %s", ErrBalanceMismatch, previous_balance.String(), balance.String())
+			}
+
+			representative := types.Address{}
+			if current != nil {
+				representative = *current.Representative
+			}
+
+			if *block.Representative != representative {
+				return nil, fmt.Errorf("%w: epoch block changes it from %s to %s", ErrRepresentativeMismatch, representative.ToNanoAddress(), block.Representative.ToNanoAddress())
+			}
+
+			// Accounts are upgraded one epoch at a time, new accounts can be opened at any epoch
+			if current != nil && block_epoch != epoch+1 {
+				return nil, fmt.Errorf("%w: epoch block upgrades the account from epoch %d to %d", ErrBlockPosition, epoch, block_epoch)
+			}
+
+			epoch = block_epoch
+
+			break
+		}
+
+		switch balance.Cmp(previous_balance) {
+		case -1:
+			changes.AddReceivable = newReceivable(block, previous_balance.Sub(balance), epoch)
+		case 1:
+			if isGenesisOpen(block) {
+				break
+			}
+
+			receivable, err := getReceivable(view, block)
+			if err != nil {
+				return nil, err
+			}
+
+			received := balance.Sub(previous_balance)
+			if received.Cmp(receivable.Info.Amount) != 0 {
+				return nil, fmt.Errorf("%w: received %s but the send was for %s", ErrBalanceMismatch, received.String(), receivable.Info.Amount.String())
+			}
+
+			epoch = maxEpoch(epoch, receivable.Info.Epoch)
+			changes.RemoveReceivable = &receivable.Key
+		}
+	default:
+		return nil, fmt.Errorf("unknown block type %d", block.Type)
+	}
+
+	// Legacy send and receive blocks don't carry a representative, the account keeps its current one
+	representative := block.Representative
+	if representative == nil && current != nil {
+		representative = current.Representative
+	}
+
+	changes.Sideband = nextSideband(current, block.Account, balance, epoch)
+	changes.Sideband.Subtype = ResolveSubtype(block, previous_balance, balance)
+	changes.Account = &AccountInfo{
+		Frontier:       block.Hash,
+		Representative: representative,
+		Sideband:       changes.Sideband,
+	}
+
+	changes.WeightRemoved, changes.WeightAdded = weightChanges(current, representative, balance)
+
+	return changes, nil
+}
+
+// Once an account has a state block or was upgraded past epoch 0, it can only be extended with state blocks
+func checkLegacyPosition(view View, block *types.Block, current *AccountInfo) error {
+	if block.Type == types.BLOCK_TYPE_STATE || current == nil {
+		return nil
+	}
+
+	if current.Epoch() > types.EPOCH_0 {
+		return fmt.Errorf("%w: legacy block on an account at epoch %d", ErrBlockPosition, current.Epoch())
+	}
+
+	frontier, err := view.GetBlock(current.Frontier)
+	if err != nil {
+		return err
+	}
+
+	if frontier != nil && frontier.Type == types.BLOCK_TYPE_STATE {
+		return fmt.Errorf("%w: legacy block following state block %s", ErrBlockPosition, current.Frontier.ToHexString())
+	}
+
+	return nil
+}
+
+func nextSideband(current *AccountInfo, account *types.Address, balance types.Amount, epoch byte) *types.Sideband {
+	height := big.NewInt(1)
+	if current != nil {
+		height = new(big.Int).Add(current.Sideband.Height, big.NewInt(1)) // Increase height by 1
+	}
+
+	return &types.Sideband{
+		Height:    height,
+		Account:   account,
+		Balance:   balance,
+		Timestamp: uint(time.Now().Unix()),
+		Epoch:     epoch,
+	}
+}
+
+func maxEpoch(a byte, b byte) byte {
+	if a > b {
+		return a
+	}
+
+	return b
+}
+
+func newReceivable(send *types.Block, amount types.Amount, epoch byte) *Receivable {
+	return &Receivable{
+		Key: ReceivableKey{
+			Destination: types.Address(*send.Link),
+			SendHash:    *send.Hash,
+		},
+		Info: ReceivableInfo{
+			Source: send.Account,
+			Amount: amount,
+			Epoch:  epoch,
+		},
+	}
+}
+
+// Looks up the send that a receive (or open) block is pocketing, the send's hash is in the block's link
+func getReceivable(view View, block *types.Block) (*Receivable, error) {
+	key := ReceivableKey{
+		Destination: *block.Account,
+		SendHash:    types.Hash(*block.Link),
+	}
+
+	info, err := view.GetReceivable(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if info == nil {
+		return nil, fmt.Errorf("%w: %s is not receivable by %s", ErrUnreceivable, key.SendHash.ToHexString(), key.Destination.ToNanoAddress())
+	}
+
+	return &Receivable{Key: key, Info: *info}, nil
+}
@@ -0,0 +1,335 @@
+package ledger
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Shryder/gnano/types"
+)
+
+// Ledger kept in maps, applying the changes of every block it accepts
+type testLedger struct {
+	accounts    map[types.Address]*AccountInfo
+	receivables map[ReceivableKey]*ReceivableInfo
+	sidebands   map[types.Hash]*types.Sideband
+	blocks      map[types.Hash]*types.Block
+}
+
+func newTestLedger() *testLedger {
+	return &testLedger{
+		accounts:    make(map[types.Address]*AccountInfo),
+		receivables: make(map[ReceivableKey]*ReceivableInfo),
+		sidebands:   make(map[types.Hash]*types.Sideband),
+		blocks:      make(map[types.Hash]*types.Block),
+	}
+}
+
+func (view *testLedger) GetAccountInfo(address *types.Address) (*AccountInfo, error) {
+	return view.accounts[*address], nil
+}
+
+func (view *testLedger) GetReceivable(key ReceivableKey) (*ReceivableInfo, error) {
+	return view.receivables[key], nil
+}
+
+func (view *testLedger) GetBlockSideband(hash *types.Hash) (*types.Sideband, error) {
+	return view.sidebands[*hash], nil
+}
+
+func (view *testLedger) GetBlock(hash *types.Hash) (*types.Block, error) {
+	return view.blocks[*hash], nil
+}
+
+func (view *testLedger) process(block *types.Block) (*Changes, error) {
+	changes, err := ProcessBlock(view, block)
+	if err != nil {
+		return nil, err
+	}
+
+	view.accounts[*block.Account] = changes.Account
+	view.sidebands[*block.Hash] = changes.Sideband
+	view.blocks[*block.Hash] = block
+
+	if changes.AddReceivable != nil {
+		view.receivables[changes.AddReceivable.Key] = &changes.AddReceivable.Info
+	}
+
+	if changes.RemoveReceivable != nil {
+		delete(view.receivables, *changes.RemoveReceivable)
+	}
+
+	return changes, nil
+}
+
+var (
+	genesisAccount = types.Address{1}
+	alice          = types.Address{2}
+	bob            = types.Address{3}
+
+	testGenesis = &types.Block{
+		Type:           types.BLOCK_TYPE_OPEN,
+		Hash:           &types.Hash{0xff},
+		Previous:       &types.Hash{},
+		Account:        &genesisAccount,
+		Representative: &genesisAccount,
+		Link:           (*types.Link)(&genesisAccount),
+	}
+)
+
+func stateBlock(hash byte, account types.Address, previous types.Hash, balance types.Amount, link types.Link) *types.Block {
+	return &types.Block{
+		Type:           types.BLOCK_TYPE_STATE,
+		Hash:           &types.Hash{hash},
+		Previous:       &previous,
+		Account:        &account,
+		Representative: &account,
+		Balance:        &balance,
+		Link:           &link,
+	}
+}
+
+// Ledger holding the genesis block, which sent 100 raw to alice in block 0x10
+func genesisLedger(t *testing.T) *testLedger {
+	SetGenesis(testGenesis)
+
+	view := newTestLedger()
+
+	changes, err := view.process(testGenesis)
+	if err != nil {
+		t.Fatal("genesis block was rejected:", err)
+	}
+
+	if changes.Sideband.Balance != maxSupply {
+		t.Fatal("genesis block opened with", changes.Sideband.Balance.String(), "instead of the whole supply")
+	}
+
+	send := stateBlock(0x10, genesisAccount, *testGenesis.Hash, maxSupply.Sub(types.Amount{Lo: 100}), types.Link(alice))
+	_, err = view.process(send)
+	if err != nil {
+		t.Fatal("send from genesis was rejected:", err)
+	}
+
+	return view
+}
+
+func TestOnlyGenesisCreatesFunds(t *testing.T) {
+	view := genesisLedger(t)
+
+	// Opens that receive from their own account like the genesis block does
+	legacy_open := &types.Block{
+		Type:           types.BLOCK_TYPE_OPEN,
+		Hash:           &types.Hash{0x20},
+		Previous:       &types.Hash{},
+		Account:        &bob,
+		Representative: &bob,
+		Link:           (*types.Link)(&bob),
+	}
+
+	state_open := stateBlock(0x21, bob, types.Hash{}, types.Amount{Lo: 1000}, types.Link(bob))
+
+	for _, block := range []*types.Block{legacy_open, state_open} {
+		_, err := view.process(block)
+		if !errors.Is(err, ErrUnreceivable) {
+			t.Errorf("open block %s of type %d that receives from its own account returned %v, want %v", block.Hash.ToHexString(), block.Type, err, ErrUnreceivable)
+		}
+	}
+
+	if view.accounts[bob] != nil {
+		t.Error("bob's account was opened")
+	}
+}
+
+func TestGenesisIsUnknownUntilSet(t *testing.T) {
+	genesisHash = nil
+	defer SetGenesis(testGenesis)
+
+	_, err := newTestLedger().process(testGenesis)
+	if !errors.Is(err, ErrUnreceivable) {
+		t.Errorf("genesis block returned %v before SetGenesis, want %v", err, ErrUnreceivable)
+	}
+}
+
+func TestReceive(t *testing.T) {
+	tests := []struct {
+		name    string
+		balance types.Amount
+		link    types.Link
+		err     error
+	}{
+		{"exact amount", types.Amount{Lo: 100}, types.Link{0x10}, nil},
+		{"more than sent", types.Amount{Lo: 101}, types.Link{0x10}, ErrBalanceMismatch},
+		{"less than sent", types.Amount{Lo: 99}, types.Link{0x10}, ErrBalanceMismatch},
+		{"unknown send", types.Amount{Lo: 100}, types.Link{0x11}, ErrUnreceivable},
+		{"block that is not a send", types.Amount{Lo: 100}, types.Link{0xff}, ErrUnreceivable},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			view := genesisLedger(t)
+
+			open := stateBlock(0x20, alice, types.Hash{}, test.balance, test.link)
+			changes, err := view.process(open)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("got error %v, want %v", err, test.err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if changes.Sideband.Subtype != types.BLOCK_SUBTYPE_OPEN {
+				t.Errorf("subtype is %s, want %s", changes.Sideband.Subtype, types.BLOCK_SUBTYPE_OPEN)
+			}
+
+			if len(view.receivables) != 0 {
+				t.Error("the send is still receivable after being received")
+			}
+
+			// The same send can't be received twice
+			receive := stateBlock(0x21, alice, *open.Hash, types.Amount{Lo: 200}, types.Link{0x10})
+			_, err = view.process(receive)
+			if !errors.Is(err, ErrUnreceivable) {
+				t.Errorf("receiving the send again returned %v, want %v", err, ErrUnreceivable)
+			}
+		})
+	}
+}
+
+func TestEpochBlocks(t *testing.T) {
+	epoch_v1 := epochLink("epoch v1 block")
+
+	tests := []struct {
+		name           string
+		account        types.Address
+		previous       types.Hash
+		balance        types.Amount
+		representative types.Address
+		err            error
+	}{
+		{"upgrade", genesisAccount, types.Hash{0x10}, maxSupply.Sub(types.Amount{Lo: 100}), genesisAccount, nil},
+		{"balance change", genesisAccount, types.Hash{0x10}, maxSupply.Sub(types.Amount{Lo: 200}), genesisAccount, ErrBalanceMismatch},
+		{"balance increase", genesisAccount, types.Hash{0x10}, maxSupply, genesisAccount, ErrBalanceMismatch},
+		{"representative change", genesisAccount, types.Hash{0x10}, maxSupply.Sub(types.Amount{Lo: 100}), alice, ErrRepresentativeMismatch},
+		{"open", alice, types.Hash{}, types.Amount{}, types.Address{}, nil},
+		{"open with funds", alice, types.Hash{}, types.Amount{Lo: 100}, types.Address{}, ErrBalanceMismatch},
+		{"open with a representative", alice, types.Hash{}, types.Amount{}, alice, ErrRepresentativeMismatch},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			view := genesisLedger(t)
+
+			block := stateBlock(0x30, test.account, test.previous, test.balance, epoch_v1)
+			block.Representative = &test.representative
+
+			changes, err := view.process(block)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("got error %v, want %v", err, test.err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if changes.Sideband.Epoch != types.EPOCH_1 {
+				t.Errorf("account is at epoch %d, want %d", changes.Sideband.Epoch, types.EPOCH_1)
+			}
+
+			if changes.Sideband.Subtype != types.BLOCK_SUBTYPE_EPOCH {
+				t.Errorf("subtype is %s, want %s", changes.Sideband.Subtype, types.BLOCK_SUBTYPE_EPOCH)
+			}
+
+			if changes.AddReceivable != nil || changes.RemoveReceivable != nil {
+				t.Error("epoch block changed the receivables")
+			}
+		})
+	}
+}
+
+func TestEpochOrder(t *testing.T) {
+	epoch_v1, epoch_v2 := epochLink("epoch v1 block"), epochLink("epoch v2 block")
+	balance := maxSupply.Sub(types.Amount{Lo: 100})
+
+	view := genesisLedger(t)
+
+	_, err := view.process(stateBlock(0x30, genesisAccount, types.Hash{0x10}, balance, epoch_v2))
+	if !errors.Is(err, ErrBlockPosition) {
+		t.Errorf("upgrading from epoch 0 to 2 returned %v, want %v", err, ErrBlockPosition)
+	}
+
+	_, err = view.process(stateBlock(0x31, genesisAccount, types.Hash{0x10}, balance, epoch_v1))
+	if err != nil {
+		t.Fatal("upgrade to epoch 1 was rejected:", err)
+	}
+
+	_, err = view.process(stateBlock(0x32, genesisAccount, types.Hash{0x31}, balance, epoch_v1))
+	if !errors.Is(err, ErrBlockPosition) {
+		t.Errorf("upgrading to epoch 1 twice returned %v, want %v", err, ErrBlockPosition)
+	}
+
+	_, err = view.process(stateBlock(0x33, genesisAccount, types.Hash{0x31}, balance, epoch_v2))
+	if err != nil {
+		t.Error("upgrade from epoch 1 to 2 was rejected:", err)
+	}
+
+	// New accounts don't have an epoch to upgrade from
+	open := stateBlock(0x34, bob, types.Hash{}, types.Amount{}, epoch_v2)
+	open.Representative = &types.Address{}
+
+	changes, err := view.process(open)
+	if err != nil || changes.Sideband.Epoch != types.EPOCH_2 {
+		t.Errorf("opening an account at epoch 2 returned %v", err)
+	}
+}
+
+func TestLegacyBlockPosition(t *testing.T) {
+	view := genesisLedger(t)
+
+	// Legacy blocks can follow legacy blocks
+	open := &types.Block{
+		Type:           types.BLOCK_TYPE_OPEN,
+		Hash:           &types.Hash{0x20},
+		Previous:       &types.Hash{},
+		Account:        &alice,
+		Representative: &alice,
+		Link:           &types.Link{0x10},
+	}
+
+	change := &types.Block{Type: types.BLOCK_TYPE_CHANGE, Hash: &types.Hash{0x21}, Previous: open.Hash, Representative: &bob}
+
+	for _, block := range []*types.Block{open, change} {
+		_, err := view.process(block)
+		if err != nil {
+			t.Fatalf("legacy block %s was rejected: %v", block.Hash.ToHexString(), err)
+		}
+	}
+
+	// Not once the account has a state block, even one that only upgraded it
+	upgrade := stateBlock(0x22, alice, *change.Hash, types.Amount{Lo: 100}, epochLink("epoch v1 block"))
+	upgrade.Representative = &bob
+
+	_, err := view.process(upgrade)
+	if err != nil {
+		t.Fatal("epoch upgrade was rejected:", err)
+	}
+
+	legacy_change := &types.Block{Type: types.BLOCK_TYPE_CHANGE, Hash: &types.Hash{0x23}, Previous: upgrade.Hash, Representative: &alice}
+	_, err = view.process(legacy_change)
+	if !errors.Is(err, ErrBlockPosition) {
+		t.Errorf("legacy change after an epoch block returned %v, want %v", err, ErrBlockPosition)
+	}
+
+	// The genesis account's frontier is the state send of genesisLedger
+	legacy_send := &types.Block{
+		Type:     types.BLOCK_TYPE_SEND,
+		Hash:     &types.Hash{0x11},
+		Previous: &types.Hash{0x10},
+		Balance:  &types.Amount{},
+		Link:     (*types.Link)(&bob),
+	}
+
+	_, err = view.process(legacy_send)
+	if !errors.Is(err, ErrBlockPosition) {
+		t.Errorf("legacy send after a state block returned %v, want %v", err, ErrBlockPosition)
+	}
+}
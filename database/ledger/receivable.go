@@ -0,0 +1,20 @@
+package ledger
+
+import "github.com/Shryder/gnano/types"
+
+// A send that the destination account did not receive yet
+type ReceivableKey struct {
+	Destination types.Address
+	SendHash    types.Hash
+}
+
+type ReceivableInfo struct {
+	Source *types.Address `json:"source"` // Account that sent the funds
+	Amount types.Amount   `json:"amount"`
+	Epoch  byte           `json:"epoch"` // Epoch of the send block
+}
+
+type Receivable struct {
+	Key  ReceivableKey
+	Info ReceivableInfo
+}
@@ -0,0 +1,39 @@
+package ledger
+
+import "github.com/Shryder/gnano/types"
+
+// Tells what a block does given its account's balance before and after it
+func ResolveSubtype(block *types.Block, previous_balance types.Amount, balance types.Amount) types.BlockSubtype {
+	switch block.Type {
+	case types.BLOCK_TYPE_SEND:
+		return types.BLOCK_SUBTYPE_SEND
+	case types.BLOCK_TYPE_RECEIVE:
+		return types.BLOCK_SUBTYPE_RECEIVE
+	case types.BLOCK_TYPE_OPEN:
+		return types.BLOCK_SUBTYPE_OPEN
+	case types.BLOCK_TYPE_CHANGE:
+		return types.BLOCK_SUBTYPE_CHANGE
+	}
+
+	if isGenesisOpen(block) {
+		return types.BLOCK_SUBTYPE_OPEN
+	}
+
+	switch balance.Cmp(previous_balance) {
+	case -1:
+		return types.BLOCK_SUBTYPE_SEND
+	case 1:
+		if block.IsOpenBlock() {
+			return types.BLOCK_SUBTYPE_OPEN
+		}
+
+		return types.BLOCK_SUBTYPE_RECEIVE
+	}
+
+	// Epoch blocks can open an account that has funds waiting for it, without receiving them
+	if _, is_epoch := EpochOfLink(block.Link); is_epoch {
+		return types.BLOCK_SUBTYPE_EPOCH
+	}
+
+	return types.BLOCK_SUBTYPE_CHANGE
+}
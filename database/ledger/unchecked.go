@@ -0,0 +1,9 @@
+package ledger
+
+import "github.com/Shryder/gnano/types"
+
+// A block that was downloaded but isn't confirmed yet
+type UncheckedInfo struct {
+	Block    types.Block `json:"block"`
+	Received int64       `json:"received"` // Unix milliseconds of when the block was first seen, used to expire it
+}
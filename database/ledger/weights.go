@@ -0,0 +1,59 @@
+package ledger
+
+import (
+	"log"
+
+	"github.com/Shryder/gnano/types"
+)
+
+// Voting weight delegated to (or taken from) a representative by a block
+type WeightChange struct {
+	Representative types.Address
+	Amount         types.Amount
+}
+
+// The account's previous balance leaves its previous representative and its new balance goes to its new representative
+func weightChanges(current *AccountInfo, representative *types.Address, balance types.Amount) (removed *WeightChange, added *WeightChange) {
+	if current != nil && current.Representative != nil && !current.Balance().IsZero() {
+		removed = &WeightChange{Representative: *current.Representative, Amount: current.Balance()}
+	}
+
+	if representative != nil && !balance.IsZero() {
+		added = &WeightChange{Representative: *representative, Amount: balance}
+	}
+
+	return removed, added
+}
+
+// Representatives whose weight is modified by the block
+func (changes *Changes) Representatives() []types.Address {
+	representatives := make([]types.Address, 0, 2)
+	if changes.WeightRemoved != nil {
+		representatives = append(representatives, changes.WeightRemoved.Representative)
+	}
+
+	if changes.WeightAdded != nil && (changes.WeightRemoved == nil || changes.WeightAdded.Representative != changes.WeightRemoved.Representative) {
+		representatives = append(representatives, changes.WeightAdded.Representative)
+	}
+
+	return representatives
+}
+
+// Applies the weight changes of a block to the current weight of representative
+func (changes *Changes) AdjustWeight(representative types.Address, weight types.Amount) types.Amount {
+	if changes.WeightRemoved != nil && changes.WeightRemoved.Representative == representative {
+		if weight.Cmp(changes.WeightRemoved.Amount) < 0 {
+			// Only happens when the weights table doesn't match the ledger, e.g. a ledger written before weights were tracked
+			log.Println("Voting weight of", representative.ToNanoAddress(), "would go negative, resetting it to 0")
+			weight = types.Amount{}
+		} else {
+			weight = weight.Sub(changes.WeightRemoved.Amount)
+		}
+	}
+
+	if changes.WeightAdded != nil && changes.WeightAdded.Representative == representative {
+		weight = weight.Add(changes.WeightAdded.Amount)
+	}
+
+	return weight
+}
@@ -0,0 +1,120 @@
+package database
+
+import (
+	"log"
+
+	"github.com/Shryder/gnano/database/ledger"
+	"github.com/Shryder/gnano/types"
+)
+
+func (backend *MemoryBackend) GetAccount(public_address *types.Address) *types.Account {
+	backend.DataMutex.RLock()
+	defer backend.DataMutex.RUnlock()
+
+	account_json, found := backend.Data.Accounts[public_address.ToHexString()]
+	if !found {
+		return nil
+	}
+
+	frontier_block_json, found := backend.Data.Blocks[account_json.Frontier.ToHexString()]
+	if !found {
+		log.Println("Account's frontier was not found.")
+		return nil
+	}
+
+	return &types.Account{
+		Frontier: frontier_block_json,
+		Sideband: *account_json.Sideband,
+	}
+}
+
+func (backend *MemoryBackend) GetAccountCount() uint64 {
+	backend.DataMutex.RLock()
+	defer backend.DataMutex.RUnlock()
+
+	return uint64(len(backend.Data.Accounts))
+}
+
+func (backend *MemoryBackend) GetAccountChain(address *types.Address) []string {
+	backend.DataMutex.RLock()
+	defer backend.DataMutex.RUnlock()
+
+	chain := make([]string, 0)
+	account, found := backend.Data.Accounts[address.ToHexString()]
+	if !found {
+		return chain
+	}
+
+	cursor := account.Frontier
+
+	for {
+		block, found := backend.Data.Blocks[cursor.ToHexString()]
+		if !found {
+			if !backend.Data.Pruned[cursor.ToHexString()] {
+				log.Println("Block", cursor.ToHexString(), "of account", address.ToNanoAddress(), "was not found while walking its chain.")
+			}
+
+			return chain
+		}
+
+		chain = append(chain, block.Hash.ToHexString())
+
+		if block.IsOpenBlock() {
+			return chain
+		}
+
+		cursor = block.Previous
+	}
+}
+
+func (backend *MemoryBackend) ForEachAccount(callback func(address *types.Address) error) error {
+	// Copy the keys so that callback is free to call back into the backend
+	backend.DataMutex.RLock()
+	addresses := make([]string, 0, len(backend.Data.Accounts))
+	for address := range backend.Data.Accounts {
+		addresses = append(addresses, address)
+	}
+	backend.DataMutex.RUnlock()
+
+	for _, address_str := range addresses {
+		address, err := types.StringPublicKeyToAddress(address_str)
+		if err != nil {
+			return err
+		}
+
+		err = callback(address)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (backend *MemoryBackend) GetRandomAccountAddress() *types.Address {
+	backend.DataMutex.RLock()
+	defer backend.DataMutex.RUnlock()
+
+	for address := range backend.Data.Accounts {
+		addy, err := types.StringPublicKeyToAddress(address)
+		if err != nil {
+			continue
+		}
+
+		return addy
+	}
+
+	return nil
+}
+
+func (backend *MemoryBackend) StoreAccount(account *types.Account) error {
+	backend.DataMutex.Lock()
+	defer backend.DataMutex.Unlock()
+
+	backend.Data.Accounts[account.Frontier.Account.ToHexString()] = ledger.AccountInfo{
+		Frontier: account.Frontier.Hash,
+		Sideband: &account.Sideband,
+	}
+
+	return nil
+}
@@ -0,0 +1,72 @@
+package database
+
+import (
+	"github.com/Shryder/gnano/types"
+)
+
+func (backend *MemoryBackend) GetBlockCount() uint64 {
+	backend.DataMutex.RLock()
+	defer backend.DataMutex.RUnlock()
+
+	return uint64(len(backend.Data.Blocks))
+}
+
+func (backend *MemoryBackend) GetBlock(hash *types.Hash) *types.Block {
+	backend.DataMutex.RLock()
+	defer backend.DataMutex.RUnlock()
+
+	block, found := backend.Data.Blocks[hash.ToHexString()]
+	if !found {
+		return nil
+	}
+
+	return &block
+}
+
+func (backend *MemoryBackend) GetBlockSideband(hash *types.Hash) *types.Sideband {
+	backend.DataMutex.RLock()
+	defer backend.DataMutex.RUnlock()
+
+	sideband, found := backend.Data.Sidebands[hash.ToHexString()]
+	if !found {
+		return nil
+	}
+
+	return &sideband
+}
+
+func (backend *MemoryBackend) GetBlockAtHeight(address *types.Address, height uint64) *types.Block {
+	backend.DataMutex.RLock()
+	defer backend.DataMutex.RUnlock()
+
+	chain := backend.Data.Chains[address.ToHexString()]
+	base := backend.Data.ChainBases[address.ToHexString()]
+	if height <= base || height > base+uint64(len(chain)) {
+		return nil
+	}
+
+	block, found := backend.Data.Blocks[chain[height-base-1]]
+	if !found {
+		return nil
+	}
+
+	return &block
+}
+
+func (backend *MemoryBackend) PutBlock(block *types.Block) error {
+	return backend.PutBlocks([]*types.Block{block})
+}
+
+func (backend *MemoryBackend) PutBlocks(blocks []*types.Block) error {
+	backend.DataMutex.Lock()
+	defer backend.DataMutex.Unlock()
+
+	staged, err := backend.StageBlocks(blocks)
+	if err != nil {
+		return err
+	}
+
+	staged.Apply()
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package database
+
+import (
+	"github.com/Shryder/gnano/database/ledger"
+	"github.com/Shryder/gnano/types"
+)
+
+// Implements ledger.CementView on top of the backend's data, callers hold DataMutex
+type cementView struct {
+	backend *MemoryBackend
+}
+
+func (view cementView) GetBlock(hash *types.Hash) (*types.Block, error) {
+	block, found := view.backend.Data.Blocks[hash.ToHexString()]
+	if !found {
+		return nil, nil
+	}
+
+	return &block, nil
+}
+
+func (view cementView) GetBlockSideband(hash *types.Hash) (*types.Sideband, error) {
+	sideband, found := view.backend.Data.Sidebands[hash.ToHexString()]
+	if !found {
+		return nil, nil
+	}
+
+	return &sideband, nil
+}
+
+func (view cementView) GetConfirmationHeight(address *types.Address) (*ledger.ConfirmationHeight, error) {
+	confirmation_height, found := view.backend.Data.ConfirmationHeights[address.ToHexString()]
+	if !found {
+		return nil, nil
+	}
+
+	return &confirmation_height, nil
+}
+
+func (view cementView) IsBlockPruned(hash *types.Hash) (bool, error) {
+	return view.backend.Data.Pruned[hash.ToHexString()], nil
+}
+
+func (backend *MemoryBackend) GetConfirmationHeight(address *types.Address) *ledger.ConfirmationHeight {
+	backend.DataMutex.RLock()
+	defer backend.DataMutex.RUnlock()
+
+	confirmation_height, _ := cementView{backend}.GetConfirmationHeight(address)
+
+	return confirmation_height
+}
+
+func (backend *MemoryBackend) GetCementedCount() uint64 {
+	backend.DataMutex.RLock()
+	defer backend.DataMutex.RUnlock()
+
+	return backend.Data.CementedCount
+}
+
+func (backend *MemoryBackend) CementBlock(hash *types.Hash) ([]*types.Hash, error) {
+	backend.DataMutex.Lock()
+	defer backend.DataMutex.Unlock()
+
+	cementing, err := backend.PlanCementing(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	backend.ApplyCementing(cementing)
+
+	return cementing.Blocks, nil
+}
+
+// Works out the confirmation heights that cementing hash leads to, callers hold DataMutex
+func (backend *MemoryBackend) PlanCementing(hash *types.Hash) (*ledger.Cementing, error) {
+	return ledger.Cement(cementView{backend}, hash)
+}
+
+// Callers hold DataMutex
+func (backend *MemoryBackend) ApplyCementing(cementing *ledger.Cementing) {
+	for account, confirmation_height := range cementing.Heights {
+		backend.Data.ConfirmationHeights[account.ToHexString()] = confirmation_height
+	}
+
+	backend.Data.CementedCount += uint64(len(cementing.Blocks))
+}
@@ -0,0 +1,70 @@
+package database
+
+import (
+	"sync"
+
+	"github.com/Shryder/gnano/database/ledger"
+	"github.com/Shryder/gnano/types"
+)
+
+type DBSchema struct {
+	Version uint64 `json:"version"` // Layout version, ledgers written before it existed are at 0
+
+	Nodes               map[string]uint                             `json:"nodes"`                // ip => discovery_timestamp
+	Blocks              map[string]types.Block                      `json:"blocks"`               // hash => block
+	Sidebands           map[string]types.Sideband                   `json:"sidebands"`            // hash => sideband
+	Chains              map[string][]string                         `json:"chains"`               // public_key => hashes of the stored blocks ordered by height
+	ChainBases          map[string]uint64                           `json:"chain_bases"`          // public_key => blocks pruned from the bottom of the chain, Chains starts above them
+	Accounts            map[string]ledger.AccountInfo               `json:"accounts"`             // public_key => account
+	BootstrapWeights    map[string]types.Amount                     `json:"weights"`              // public_key => weight seeded from weights.json
+	RepWeights          map[string]types.Amount                     `json:"rep_weights"`          // public_key => weight delegated in the ledger
+	ConfirmationHeights map[string]ledger.ConfirmationHeight        `json:"confirmation_heights"` // public_key => highest cemented block
+	CementedCount       uint64                                      `json:"cemented_count"`
+	Receivables         map[string]map[string]ledger.ReceivableInfo `json:"receivables"` // destination public_key => send_hash => receivable
+	Pruned              map[string]bool                             `json:"pruned"`      // hash => true for cemented blocks discarded by pruning, the only trace left of them
+	Unchecked           map[string]ledger.UncheckedInfo             `json:"unchecked"`   // hash => downloaded block waiting for confirmation
+}
+
+// Empty ledger with the bootstrap weights filled in
+func NewSchema(initialWeights map[string]types.Amount) DBSchema {
+	bootstrapWeights := make(map[string]types.Amount, len(initialWeights))
+	for address, weight := range initialWeights {
+		bootstrapWeights[address] = weight
+	}
+
+	return DBSchema{
+		Nodes:               make(map[string]uint),
+		Blocks:              make(map[string]types.Block),
+		Sidebands:           make(map[string]types.Sideband),
+		Chains:              make(map[string][]string),
+		ChainBases:          make(map[string]uint64),
+		Accounts:            make(map[string]ledger.AccountInfo),
+		BootstrapWeights:    bootstrapWeights,
+		RepWeights:          make(map[string]types.Amount),
+		ConfirmationHeights: make(map[string]ledger.ConfirmationHeight),
+		Receivables:         make(map[string]map[string]ledger.ReceivableInfo),
+		Pruned:              make(map[string]bool),
+		Unchecked:           make(map[string]ledger.UncheckedInfo),
+	}
+}
+
+// Keeps the whole ledger in memory and never touches disk. Used for tests and throwaway nodes, and by the JSON backend which snapshots it to a file.
+type MemoryBackend struct {
+	Data      DBSchema
+	DataMutex sync.RWMutex
+}
+
+func (backend *MemoryBackend) BackendName() string {
+	return "Memory"
+}
+
+func (backend *MemoryBackend) Cleanup() error {
+	return nil
+}
+
+// initialWeights maps public keys (hex) to the weights used until the ledger is synced, it can be nil
+func New(initialWeights map[string]types.Amount) *MemoryBackend {
+	return &MemoryBackend{
+		Data: NewSchema(initialWeights),
+	}
+}
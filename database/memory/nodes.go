@@ -2,7 +2,7 @@ package database
 
 import "time"
 
-func (backend *JSONBackend) AddNodeIPs(addresses []string) error {
+func (backend *MemoryBackend) AddNodeIPs(addresses []string) error {
 	backend.DataMutex.Lock()
 	defer backend.DataMutex.Unlock()
 
@@ -15,7 +15,7 @@ func (backend *JSONBackend) AddNodeIPs(addresses []string) error {
 	return nil
 }
 
-func (backend *JSONBackend) GetNodeIPs() (map[string]uint, error) {
+func (backend *MemoryBackend) GetNodeIPs() (map[string]uint, error) {
 	backend.DataMutex.RLock()
 	defer backend.DataMutex.RUnlock()
 
@@ -0,0 +1,85 @@
+package database
+
+import (
+	"github.com/Shryder/gnano/types"
+)
+
+func (backend *MemoryBackend) IsBlockPruned(hash *types.Hash) bool {
+	backend.DataMutex.RLock()
+	defer backend.DataMutex.RUnlock()
+
+	return backend.Data.Pruned[hash.ToHexString()]
+}
+
+func (backend *MemoryBackend) GetPrunedCount() uint64 {
+	backend.DataMutex.RLock()
+	defer backend.DataMutex.RUnlock()
+
+	return uint64(len(backend.Data.Pruned))
+}
+
+// Discards the account's cemented blocks that are more than depth blocks below its confirmation height.
+// Their hashes leave the chain index too, ChainBases keeps the heights of the remaining blocks from moving.
+// The JSON backend doesn't journal pruning, a pass lost in a crash is simply redone by the next one.
+func (backend *MemoryBackend) PruneAccount(address *types.Address, depth uint64) (uint64, error) {
+	backend.DataMutex.Lock()
+	defer backend.DataMutex.Unlock()
+
+	confirmation_height, found := backend.Data.ConfirmationHeights[address.ToHexString()]
+	if !found || depth == 0 || confirmation_height.Height <= depth {
+		return 0, nil
+	}
+
+	// Skip the blocks that are kept
+	cursor := &confirmation_height.Frontier
+	for kept := uint64(0); kept < depth; kept++ {
+		block, found := backend.Data.Blocks[cursor.ToHexString()]
+		if !found || block.IsOpenBlock() {
+			return 0, nil
+		}
+
+		cursor = block.Previous
+	}
+
+	// Everything below the first block that was already pruned was pruned along with it
+	pruned := uint64(0)
+	for {
+		block, found := backend.Data.Blocks[cursor.ToHexString()]
+		if !found {
+			break
+		}
+
+		delete(backend.Data.Blocks, cursor.ToHexString())
+		delete(backend.Data.Sidebands, cursor.ToHexString())
+		backend.Data.Pruned[cursor.ToHexString()] = true
+		pruned++
+
+		if block.IsOpenBlock() {
+			break
+		}
+
+		cursor = block.Previous
+	}
+
+	TrimPrunedChain(&backend.Data, address.ToHexString())
+
+	return pruned, nil
+}
+
+// Drops the hashes of pruned blocks from the bottom of the account's chain index. Callers hold DataMutex.
+func TrimPrunedChain(data *DBSchema, account string) {
+	chain := data.Chains[account]
+
+	trimmed := 0
+	for trimmed < len(chain) && data.Pruned[chain[trimmed]] {
+		trimmed++
+	}
+
+	if trimmed == 0 {
+		return
+	}
+
+	// Copied so that the pruned hashes can be garbage collected
+	data.Chains[account] = append([]string{}, chain[trimmed:]...)
+	data.ChainBases[account] += uint64(trimmed)
+}
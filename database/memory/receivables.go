@@ -0,0 +1,39 @@
+package database
+
+import (
+	"github.com/Shryder/gnano/database/ledger"
+	"github.com/Shryder/gnano/types"
+)
+
+func (backend *MemoryBackend) getReceivable(key ledger.ReceivableKey) *ledger.ReceivableInfo {
+	info, found := backend.Data.Receivables[key.Destination.ToHexString()][key.SendHash.ToHexString()]
+	if !found {
+		return nil
+	}
+
+	return &info
+}
+
+func (backend *MemoryBackend) GetReceivable(destination *types.Address, sendHash *types.Hash) *ledger.ReceivableInfo {
+	backend.DataMutex.RLock()
+	defer backend.DataMutex.RUnlock()
+
+	return backend.getReceivable(ledger.ReceivableKey{Destination: *destination, SendHash: *sendHash})
+}
+
+func (backend *MemoryBackend) GetReceivables(destination *types.Address) map[types.Hash]ledger.ReceivableInfo {
+	backend.DataMutex.RLock()
+	defer backend.DataMutex.RUnlock()
+
+	receivables := make(map[types.Hash]ledger.ReceivableInfo)
+	for hash_str, info := range backend.Data.Receivables[destination.ToHexString()] {
+		hash, err := types.StringToHash(hash_str)
+		if err != nil {
+			continue
+		}
+
+		receivables[*hash] = info
+	}
+
+	return receivables
+}
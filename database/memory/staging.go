@@ -0,0 +1,182 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/Shryder/gnano/database/ledger"
+	"github.com/Shryder/gnano/types"
+)
+
+// Pending writes of a batch of blocks. Implements ledger.View by reading through the pending writes first,
+// so that blocks later in the batch see the ones before them while the ledger itself stays untouched until Apply.
+type Staging struct {
+	backend *MemoryBackend
+
+	blocks      []*types.Block
+	sidebands   map[types.Hash]types.Sideband
+	accounts    map[string]ledger.AccountInfo
+	receivables map[ledger.ReceivableKey]*ledger.ReceivableInfo // nil entries are receivables that got received
+	weights     map[types.Address]types.Amount
+}
+
+func (backend *MemoryBackend) newStaging() *Staging {
+	return &Staging{
+		backend:     backend,
+		blocks:      make([]*types.Block, 0),
+		sidebands:   make(map[types.Hash]types.Sideband),
+		accounts:    make(map[string]ledger.AccountInfo),
+		receivables: make(map[ledger.ReceivableKey]*ledger.ReceivableInfo),
+		weights:     make(map[types.Address]types.Amount),
+	}
+}
+
+func (staged *Staging) GetAccountInfo(address *types.Address) (*ledger.AccountInfo, error) {
+	if account, ok := staged.accounts[address.ToHexString()]; ok {
+		return &account, nil
+	}
+
+	if account, ok := staged.backend.Data.Accounts[address.ToHexString()]; ok {
+		return &account, nil
+	}
+
+	return nil, nil
+}
+
+func (staged *Staging) GetReceivable(key ledger.ReceivableKey) (*ledger.ReceivableInfo, error) {
+	if info, ok := staged.receivables[key]; ok {
+		return info, nil
+	}
+
+	return staged.backend.getReceivable(key), nil
+}
+
+func (staged *Staging) GetBlockSideband(hash *types.Hash) (*types.Sideband, error) {
+	if sideband, ok := staged.sidebands[*hash]; ok {
+		return &sideband, nil
+	}
+
+	if sideband, ok := staged.backend.Data.Sidebands[hash.ToHexString()]; ok {
+		return &sideband, nil
+	}
+
+	return nil, nil
+}
+
+func (staged *Staging) GetBlock(hash *types.Hash) (*types.Block, error) {
+	// Blocks usually build on the one staged right before them
+	for i := len(staged.blocks) - 1; i >= 0; i-- {
+		if *staged.blocks[i].Hash == *hash {
+			return staged.blocks[i], nil
+		}
+	}
+
+	if block, ok := staged.backend.Data.Blocks[hash.ToHexString()]; ok {
+		return &block, nil
+	}
+
+	return nil, nil
+}
+
+func (staged *Staging) getWeight(representative types.Address) types.Amount {
+	if weight, ok := staged.weights[representative]; ok {
+		return weight
+	}
+
+	return staged.backend.Data.RepWeights[representative.ToHexString()]
+}
+
+func (staged *Staging) add(block *types.Block, changes *ledger.Changes) {
+	staged.blocks = append(staged.blocks, block)
+	staged.accounts[block.Account.ToHexString()] = *changes.Account
+	staged.sidebands[*block.Hash] = *changes.Sideband
+
+	if !block.IsOpenBlock() {
+		// Ledgers saved before blocks had sidebands have no sideband for the previous block
+		previous, _ := staged.GetBlockSideband(block.Previous)
+		if previous != nil {
+			previous.Successor = block.Hash
+			staged.sidebands[*block.Previous] = *previous
+		}
+	}
+
+	if changes.AddReceivable != nil {
+		staged.receivables[changes.AddReceivable.Key] = &changes.AddReceivable.Info
+	}
+
+	if changes.RemoveReceivable != nil {
+		staged.receivables[*changes.RemoveReceivable] = nil
+	}
+
+	for _, representative := range changes.Representatives() {
+		staged.weights[representative] = changes.AdjustWeight(representative, staged.getWeight(representative))
+	}
+}
+
+// Validates blocks on top of the current ledger and stages the writes they lead to.
+// Nothing is modified, so a failing batch leaves the ledger untouched. Callers hold DataMutex until the staged writes are applied.
+func (backend *MemoryBackend) StageBlocks(blocks []*types.Block) (*Staging, error) {
+	if len(blocks) > ledger.MAX_PUT_BLOCKS {
+		return nil, fmt.Errorf("%w: got %d", ledger.ErrBatchTooLarge, len(blocks))
+	}
+
+	staged := backend.newStaging()
+	for i, block := range blocks {
+		changes, err := ledger.ProcessBlock(staged, block)
+		if err != nil {
+			return nil, &ledger.BlockError{Index: i, Hash: block.Hash, Err: err}
+		}
+
+		staged.add(block, changes)
+	}
+
+	return staged, nil
+}
+
+func (staged *Staging) Apply() {
+	data := &staged.backend.Data
+
+	for _, block := range staged.blocks {
+		data.Blocks[block.Hash.ToHexString()] = *block
+
+		// Chains of ledgers saved before blocks had sidebands are incomplete and not indexed
+		account := block.Account.ToHexString()
+		if data.ChainBases[account]+uint64(len(data.Chains[account])+1) == staged.sidebands[*block.Hash].Height.Uint64() {
+			data.Chains[account] = append(data.Chains[account], block.Hash.ToHexString())
+		}
+	}
+
+	for hash, sideband := range staged.sidebands {
+		data.Sidebands[hash.ToHexString()] = sideband
+	}
+
+	for address, account := range staged.accounts {
+		data.Accounts[address] = account
+	}
+
+	for key, info := range staged.receivables {
+		destination := key.Destination.ToHexString()
+		if info == nil {
+			delete(data.Receivables[destination], key.SendHash.ToHexString())
+			if len(data.Receivables[destination]) == 0 {
+				delete(data.Receivables, destination)
+			}
+
+			continue
+		}
+
+		if _, found := data.Receivables[destination]; !found {
+			data.Receivables[destination] = make(map[string]ledger.ReceivableInfo)
+		}
+
+		data.Receivables[destination][key.SendHash.ToHexString()] = *info
+	}
+
+	for representative, weight := range staged.weights {
+		if weight.IsZero() {
+			delete(data.RepWeights, representative.ToHexString())
+			continue
+		}
+
+		data.RepWeights[representative.ToHexString()] = weight
+	}
+}
@@ -0,0 +1,48 @@
+package database
+
+import (
+	"github.com/Shryder/gnano/database/ledger"
+	"github.com/Shryder/gnano/types"
+)
+
+// The JSON backend doesn't journal the unchecked table, it's saved with the next snapshot
+func (backend *MemoryBackend) PutUnchecked(entries []*ledger.UncheckedInfo) error {
+	backend.DataMutex.Lock()
+	defer backend.DataMutex.Unlock()
+
+	for _, entry := range entries {
+		backend.Data.Unchecked[entry.Block.Hash.ToHexString()] = *entry
+	}
+
+	return nil
+}
+
+func (backend *MemoryBackend) DeleteUnchecked(hashes []types.Hash) error {
+	backend.DataMutex.Lock()
+	defer backend.DataMutex.Unlock()
+
+	for _, hash := range hashes {
+		delete(backend.Data.Unchecked, hash.ToHexString())
+	}
+
+	return nil
+}
+
+func (backend *MemoryBackend) ForEachUnchecked(callback func(entry *ledger.UncheckedInfo) error) error {
+	// Copied so that the callback can write to the backend
+	backend.DataMutex.RLock()
+	entries := make([]ledger.UncheckedInfo, 0, len(backend.Data.Unchecked))
+	for _, entry := range backend.Data.Unchecked {
+		entries = append(entries, entry)
+	}
+	backend.DataMutex.RUnlock()
+
+	for i := range entries {
+		err := callback(&entries[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
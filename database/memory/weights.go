@@ -0,0 +1,70 @@
+package database
+
+import (
+	"github.com/Shryder/gnano/types"
+)
+
+func (backend *MemoryBackend) GetVotingWeight(address *types.Address) types.Amount {
+	backend.DataMutex.RLock()
+	defer backend.DataMutex.RUnlock()
+
+	weight, found := backend.Data.RepWeights[address.ToHexString()]
+	if !found {
+		return types.Amount{}
+	}
+
+	return weight
+}
+
+func (backend *MemoryBackend) GetBootstrapWeight(address *types.Address) types.Amount {
+	backend.DataMutex.RLock()
+	defer backend.DataMutex.RUnlock()
+
+	weight, found := backend.Data.BootstrapWeights[address.ToHexString()]
+	if !found {
+		return types.Amount{}
+	}
+
+	return weight
+}
+
+func (backend *MemoryBackend) PutBootstrapWeight(address *types.Address, weight types.Amount) error {
+	backend.DataMutex.Lock()
+	defer backend.DataMutex.Unlock()
+
+	backend.Data.BootstrapWeights[address.ToHexString()] = weight
+
+	return nil
+}
+
+func (backend *MemoryBackend) ForEachVotingWeight(callback func(address *types.Address, weight types.Amount) error) error {
+	return backend.forEachWeight(backend.Data.RepWeights, callback)
+}
+
+func (backend *MemoryBackend) ForEachBootstrapWeight(callback func(address *types.Address, weight types.Amount) error) error {
+	return backend.forEachWeight(backend.Data.BootstrapWeights, callback)
+}
+
+func (backend *MemoryBackend) forEachWeight(table map[string]types.Amount, callback func(address *types.Address, weight types.Amount) error) error {
+	// Copy the table so that callback is free to call back into the backend
+	backend.DataMutex.RLock()
+	weights := make(map[string]types.Amount, len(table))
+	for address, weight := range table {
+		weights[address] = weight
+	}
+	backend.DataMutex.RUnlock()
+
+	for address_str, weight := range weights {
+		address, err := types.StringPublicKeyToAddress(address_str)
+		if err != nil {
+			return err
+		}
+
+		err = callback(address, weight)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
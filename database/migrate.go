@@ -0,0 +1,262 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/Shryder/gnano/database/ledger"
+	"github.com/Shryder/gnano/types"
+)
+
+// Amount of blocks of the same chain written per PutBlocks call
+const MIGRATION_BATCH_SIZE = ledger.MAX_PUT_BLOCKS
+
+// Copies every block, account, node IP and bootstrap weight from one backend into another, representative weights are rebuilt as the blocks are inserted.
+// Blocks are inserted one account chain at a time starting from the open block, the same order PutBlock expects them in,
+// chains blocked on a receive are resumed once the matching send was migrated.
+func MigrateLedger(from DatabaseBackend, to DatabaseBackend) error {
+	return migrateLedger(from, to, false)
+}
+
+// Like MigrateLedger, but chains that receive a send missing from the source are cut at that receive instead of failing
+// the whole migration. Ledgers stored before receives were checked have such chains, the blocks left out of the
+// rebuilt ledger are synced again like any other missing block.
+func rebuildLedger(from DatabaseBackend, to DatabaseBackend) error {
+	return migrateLedger(from, to, true)
+}
+
+// Blocks left out of a rebuilt ledger
+type droppedBlocks struct {
+	Blocks   uint64
+	Accounts uint64 // Accounts whose open block was left out, along with the rest of their chain
+}
+
+func migrateLedger(from DatabaseBackend, to DatabaseBackend, drop_unreceivable bool) error {
+	if to.GetBlockCount() != 0 || to.GetAccountCount() != 0 {
+		return fmt.Errorf("refusing to migrate into %s backend because it already has %d blocks and %d accounts", to.BackendName(), to.GetBlockCount(), to.GetAccountCount())
+	}
+
+	// Chains are rebuilt from their open block, which a pruned ledger doesn't have anymore.
+	// Pruned ledgers can only be moved to another backend by syncing a new one.
+	if pruned := from.GetPrunedCount(); pruned > 0 {
+		return fmt.Errorf("refusing to migrate from %s backend because it was pruned, %d blocks were discarded", from.BackendName(), pruned)
+	}
+
+	log.Println("Migrating ledger from", from.BackendName(), "to", to.BackendName())
+
+	nodes, err := from.GetNodeIPs()
+	if err != nil {
+		return err
+	}
+
+	ips := make([]string, 0, len(nodes))
+	for ip := range nodes {
+		ips = append(ips, ip)
+	}
+
+	err = to.AddNodeIPs(ips)
+	if err != nil {
+		return fmt.Errorf("error migrating node IPs: %w", err)
+	}
+
+	log.Println("Migrated", len(ips), "node IPs")
+
+	weights_count := 0
+	err = from.ForEachBootstrapWeight(func(address *types.Address, weight types.Amount) error {
+		weights_count++
+
+		return to.PutBootstrapWeight(address, weight)
+	})
+
+	if err != nil {
+		return fmt.Errorf("error migrating bootstrap weights: %w", err)
+	}
+
+	log.Println("Migrated", weights_count, "bootstrap weights")
+
+	// Chains that stopped at a receive whose send (in another account) wasn't migrated yet
+	pending := make([][]string, 0)
+	accounts_count := uint64(0)
+	err = from.ForEachAccount(func(address *types.Address) error {
+		// GetAccountChain returns the chain starting from the frontier
+		chain := from.GetAccountChain(address)
+		for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+			chain[i], chain[j] = chain[j], chain[i]
+		}
+
+		remaining, err := migrateChain(from, to, chain)
+		if err != nil {
+			return err
+		}
+
+		if len(remaining) > 0 {
+			pending = append(pending, remaining)
+		}
+
+		accounts_count++
+		if accounts_count%10_000 == 0 {
+			log.Println("Migrated", accounts_count, "accounts so far")
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return fmt.Errorf("error migrating blocks: %w", err)
+	}
+
+	// Every pass unblocks the receives whose sends were migrated in the previous one
+	dropped := droppedBlocks{}
+	for len(pending) > 0 {
+		log.Println("Retrying", len(pending), "chains that were waiting for their sources")
+
+		progress := false
+		still_pending := make([][]string, 0)
+		for _, chain := range pending {
+			remaining, err := migrateChain(from, to, chain)
+			if err != nil {
+				return fmt.Errorf("error migrating blocks: %w", err)
+			}
+
+			if len(remaining) < len(chain) {
+				progress = true
+			}
+
+			if len(remaining) > 0 {
+				still_pending = append(still_pending, remaining)
+			}
+		}
+
+		if !progress && !drop_unreceivable {
+			return fmt.Errorf("%d chains are waiting for sends that are not in the %s ledger", len(still_pending), from.BackendName())
+		}
+
+		if !progress {
+			dropped = dropChains(from, still_pending)
+			break
+		}
+
+		pending = still_pending
+	}
+
+	err = migrateConfirmationHeights(from, to)
+	if err != nil {
+		return fmt.Errorf("error migrating confirmation heights: %w", err)
+	}
+
+	return verifyMigration(from, to, dropped)
+}
+
+// Leaves out the chains whose receive is still waiting for its send, from that receive onwards
+func dropChains(from DatabaseBackend, chains [][]string) droppedBlocks {
+	dropped := droppedBlocks{}
+	for _, chain := range chains {
+		log.Println("Leaving out", len(chain), "blocks starting at", chain[0], "because they receive a send that is not in the", from.BackendName(), "ledger")
+
+		dropped.Blocks += uint64(len(chain))
+
+		hash, err := types.StringToHash(chain[0])
+		if err == nil && from.GetBlock(hash).IsOpenBlock() {
+			dropped.Accounts++
+		}
+	}
+
+	log.Println("Left out", dropped.Blocks, "blocks of", len(chains), "chains, they have to be synced again")
+
+	return dropped
+}
+
+// Cements every account up to the same height as in the source backend, once all blocks are there.
+// Accounts whose chain was cut below that height are cemented up to where it was cut.
+func migrateConfirmationHeights(from DatabaseBackend, to DatabaseBackend) error {
+	return from.ForEachAccount(func(address *types.Address) error {
+		confirmation_height := from.GetConfirmationHeight(address)
+		if confirmation_height == nil {
+			return nil
+		}
+
+		frontier := &confirmation_height.Frontier
+		if to.GetBlock(frontier) == nil {
+			account := to.GetAccount(address)
+			if account == nil {
+				return nil
+			}
+
+			frontier = account.Frontier.Hash
+		}
+
+		_, err := to.CementBlock(frontier)
+
+		return err
+	})
+}
+
+// Makes sure both backends report the same amount of blocks, cemented blocks and accounts
+func VerifyMigration(from DatabaseBackend, to DatabaseBackend) error {
+	return verifyMigration(from, to, droppedBlocks{})
+}
+
+// Blocks that were left out are missing from the target, and so are the cemented ones among them
+func verifyMigration(from DatabaseBackend, to DatabaseBackend, dropped droppedBlocks) error {
+	if from.GetBlockCount()-dropped.Blocks != to.GetBlockCount() {
+		return fmt.Errorf("block count mismatch after migration: %s has %d blocks (%d left out) but %s has %d", from.BackendName(), from.GetBlockCount(), dropped.Blocks, to.BackendName(), to.GetBlockCount())
+	}
+
+	if from.GetAccountCount()-dropped.Accounts != to.GetAccountCount() {
+		return fmt.Errorf("account count mismatch after migration: %s has %d accounts (%d left out) but %s has %d", from.BackendName(), from.GetAccountCount(), dropped.Accounts, to.BackendName(), to.GetAccountCount())
+	}
+
+	if dropped.Blocks == 0 && from.GetCementedCount() != to.GetCementedCount() {
+		return fmt.Errorf("cemented count mismatch after migration: %s has %d cemented blocks but %s has %d", from.BackendName(), from.GetCementedCount(), to.BackendName(), to.GetCementedCount())
+	}
+
+	log.Println("Migration verified:", to.GetBlockCount(), "blocks (", to.GetCementedCount(), "cemented ) and", to.GetAccountCount(), "accounts")
+
+	return nil
+}
+
+// Writes the chain (ordered from the open block) in batches. When a receive's source isn't in the target yet,
+// everything before it is written and the hashes from the receive onwards are returned to be retried later.
+func migrateChain(from DatabaseBackend, to DatabaseBackend, chain []string) ([]string, error) {
+	for start := 0; start < len(chain); start += MIGRATION_BATCH_SIZE {
+		end := start + MIGRATION_BATCH_SIZE
+		if end > len(chain) {
+			end = len(chain)
+		}
+
+		batch := make([]*types.Block, 0, end-start)
+		for _, hash_str := range chain[start:end] {
+			hash, err := types.StringToHash(hash_str)
+			if err != nil {
+				return nil, err
+			}
+
+			block := from.GetBlock(hash)
+			if block == nil {
+				return nil, fmt.Errorf("block %s not found in %s backend", hash_str, from.BackendName())
+			}
+
+			batch = append(batch, block)
+		}
+
+		err := to.PutBlocks(batch)
+		if err == nil {
+			continue
+		}
+
+		var blockError *ledger.BlockError
+		if !errors.As(err, &blockError) || !errors.Is(err, ledger.ErrUnreceivable) {
+			return nil, err
+		}
+
+		err = to.PutBlocks(batch[:blockError.Index])
+		if err != nil {
+			return nil, err
+		}
+
+		return chain[start+blockError.Index:], nil
+	}
+
+	return nil, nil
+}
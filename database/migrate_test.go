@@ -0,0 +1,151 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+
+	badger_backend "github.com/Shryder/gnano/database/badger"
+	"github.com/Shryder/gnano/database/ledger"
+	memory_backend "github.com/Shryder/gnano/database/memory"
+	"github.com/Shryder/gnano/types"
+)
+
+var (
+	genesisAccount = types.Address{1}
+	alice          = types.Address{2}
+	bob            = types.Address{3}
+
+	maxSupply = types.Amount{Hi: ^uint64(0), Lo: ^uint64(0)}
+)
+
+func stateBlock(hash byte, account types.Address, previous byte, balance types.Amount, link types.Link) *types.Block {
+	return &types.Block{
+		Type:           types.BLOCK_TYPE_STATE,
+		Hash:           &types.Hash{hash},
+		Previous:       &types.Hash{previous},
+		Account:        &account,
+		Representative: &account,
+		Balance:        &balance,
+		Link:           &link,
+		Signature:      &types.Signature{},
+		Work:           &types.Work{},
+	}
+}
+
+// Ledger in which bob's chain receives from both genesis and alice, so it can only be migrated after theirs.
+// Genesis and alice are cemented up to their first send and open block.
+func newTestLedger(t *testing.T) *memory_backend.MemoryBackend {
+	genesis := &types.Block{
+		Type:           types.BLOCK_TYPE_OPEN,
+		Hash:           &types.Hash{0xff},
+		Previous:       &types.Hash{},
+		Account:        &genesisAccount,
+		Representative: &genesisAccount,
+		Link:           (*types.Link)(&genesisAccount),
+		Signature:      &types.Signature{},
+		Work:           &types.Work{},
+	}
+
+	ledger.SetGenesis(genesis)
+
+	backend := memory_backend.New(nil)
+	blocks := []*types.Block{
+		genesis,
+		stateBlock(0x10, genesisAccount, 0xff, maxSupply.Sub(types.Amount{Lo: 100}), types.Link(alice)),
+		stateBlock(0x11, genesisAccount, 0x10, maxSupply.Sub(types.Amount{Lo: 150}), types.Link(bob)),
+		stateBlock(0x20, alice, 0x00, types.Amount{Lo: 100}, types.Link{0x10}),
+		stateBlock(0x21, alice, 0x20, types.Amount{Lo: 70}, types.Link(bob)),
+		stateBlock(0x30, bob, 0x00, types.Amount{Lo: 30}, types.Link{0x21}),
+		stateBlock(0x31, bob, 0x30, types.Amount{Lo: 80}, types.Link{0x11}),
+	}
+
+	err := backend.PutBlocks(blocks)
+	if err != nil {
+		t.Fatal("error storing the test ledger:", err)
+	}
+
+	for _, hash := range []types.Hash{{0x11}, {0x20}} {
+		_, err = backend.CementBlock(&hash)
+		if err != nil {
+			t.Fatal("error cementing", hash.ToHexString(), err)
+		}
+	}
+
+	return backend
+}
+
+func testMigration(t *testing.T, target DatabaseBackend) {
+	source := newTestLedger(t)
+
+	err := MigrateLedger(source, target)
+	if err != nil {
+		t.Fatal("migration failed:", err)
+	}
+
+	if target.GetBlockCount() != 7 || target.GetAccountCount() != 3 || target.GetCementedCount() != 4 {
+		t.Errorf("migrated %d blocks (%d cemented) in %d accounts, want 7 (4 cemented) in 3", target.GetBlockCount(), target.GetCementedCount(), target.GetAccountCount())
+	}
+
+	for _, address := range []types.Address{genesisAccount, alice, bob} {
+		source_weight, target_weight := source.GetVotingWeight(&address), target.GetVotingWeight(&address)
+		if source_weight != target_weight {
+			t.Errorf("voting weight of %s is %s, want %s", address.ToHexString(), target_weight.String(), source_weight.String())
+		}
+
+		source_height, target_height := source.GetConfirmationHeight(&address), target.GetConfirmationHeight(&address)
+		if (source_height == nil) != (target_height == nil) || (source_height != nil && *source_height != *target_height) {
+			t.Errorf("confirmation height of %s is %+v, want %+v", address.ToHexString(), target_height, source_height)
+		}
+	}
+
+	sideband := target.GetBlockSideband(&types.Hash{0x31})
+	if sideband == nil || sideband.Height.Uint64() != 2 || sideband.Balance != (types.Amount{Lo: 80}) {
+		t.Errorf("bob's frontier has sideband %+v, want height 2 and balance 80", sideband)
+	}
+
+	if len(target.GetReceivables(&bob)) != 0 {
+		t.Error("sends bob received are receivable again")
+	}
+}
+
+func TestMigrateLedger(t *testing.T) {
+	testMigration(t, memory_backend.New(nil))
+}
+
+func TestMigrateLedgerToBadger(t *testing.T) {
+	target, err := badger_backend.Initialize(filepath.Join(t.TempDir(), "Badger"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer target.Cleanup()
+
+	testMigration(t, target)
+}
+
+func TestMigrateLedgerRefusesNonEmptyTarget(t *testing.T) {
+	err := MigrateLedger(newTestLedger(t), newTestLedger(t))
+	if err == nil {
+		t.Error("migrated into a backend that already had blocks")
+	}
+}
+
+func TestMigrateLedgerRefusesPrunedSource(t *testing.T) {
+	source := newTestLedger(t)
+
+	pruned, err := source.PruneAccount(&genesisAccount, 1)
+	if err != nil || pruned != 2 {
+		t.Fatalf("pruned %d blocks with error %v, want 2", pruned, err)
+	}
+
+	target := memory_backend.New(nil)
+
+	err = MigrateLedger(source, target)
+	if err == nil {
+		t.Error("migrated a pruned ledger")
+	}
+
+	if target.GetBlockCount() != 0 {
+		t.Error("blocks were written before the pruned source was refused")
+	}
+}
@@ -0,0 +1,82 @@
+package database
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/Shryder/gnano/types"
+)
+
+func (db *Database) validatePruningConfig() error {
+	if !db.Config.Pruning {
+		return nil
+	}
+
+	// The block at the confirmation height is needed to cement the blocks above it
+	if db.Config.PruningDepth == 0 {
+		return errors.New("PruningDepth has to be at least 1")
+	}
+
+	if db.Config.PruningInterval == 0 {
+		return errors.New("PruningInterval has to be at least 1 second")
+	}
+
+	return nil
+}
+
+var errPruningStopped = errors.New("pruning was stopped")
+
+// Runs a pruning pass over every account, returns the number of blocks discarded
+func (db *Database) Prune() (uint64, error) {
+	return db.prune(nil)
+}
+
+// The pass is abandoned once stop is closed, the accounts it didn't reach are pruned by the next one
+func (db *Database) prune(stop chan bool) (uint64, error) {
+	pruned := uint64(0)
+	err := db.Backend.ForEachAccount(func(address *types.Address) error {
+		select {
+		case <-stop:
+			return errPruningStopped
+		default:
+		}
+
+		count, err := db.Backend.PruneAccount(address, db.Config.PruningDepth)
+		pruned += count
+
+		return err
+	})
+
+	return pruned, err
+}
+
+func (db *Database) PeriodicPruning() {
+	defer close(db.PruningStopped)
+
+	ticker := time.NewTicker(time.Second * time.Duration(db.Config.PruningInterval))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-db.StopPruning:
+			return
+		case <-ticker.C:
+			started := time.Now()
+
+			pruned, err := db.prune(db.StopPruning)
+			if errors.Is(err, errPruningStopped) {
+				return
+			}
+
+			if err != nil {
+				log.Println("Error pruning the ledger:", err)
+				continue
+			}
+
+			if pruned > 0 {
+				log.Println("Pruned", pruned, "blocks in", time.Since(started), "total pruned:", db.Backend.GetPrunedCount())
+			}
+		}
+	}
+}
@@ -0,0 +1,324 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	badger_backend "github.com/Shryder/gnano/database/badger"
+	"github.com/Shryder/gnano/database/ledger"
+	memory_backend "github.com/Shryder/gnano/database/memory"
+	"github.com/Shryder/gnano/types"
+)
+
+// Layout version of the stored ledger. Changing how a backend stores blocks, accounts or sidebands means bumping it
+// and registering a migration that upgrades ledgers from the previous version.
+const SCHEMA_VERSION = uint64(7)
+
+// Upgrades a ledger from Version-1 to Version, a backend without a step has nothing to change
+type Migration struct {
+	Version     uint64
+	Description string
+
+	Memory func(data *memory_backend.DBSchema) error // Memory and JSON backends, runs before the journal is replayed
+	Badger func(backend *badger_backend.BadgerBackend) error
+}
+
+// Applied in order when a backend is opened. Ledgers written before schema versions existed are at version 0,
+// new ledgers start there too and go through every migration without anything to change.
+var MIGRATIONS = []Migration{
+	{
+		Version:     1,
+		Description: "track confirmation heights, every block stored before them was cemented",
+		Memory:      backfillConfirmationHeights,
+		Badger:      (*badger_backend.BadgerBackend).BackfillConfirmationHeights,
+	},
+	{
+		Version:     2,
+		Description: "create the tables added since the first release",
+		Memory:      createMissingTables,
+	},
+	{
+		Version:     3,
+		Description: "set the type of legacy send blocks stored without one",
+		Memory:      inferLegacySendTypes,
+		Badger:      (*badger_backend.BadgerBackend).InferLegacySendTypes,
+	},
+	{
+		Version:     4,
+		Description: "rebuild the sidebands, chains, representative weights and receivables of ledgers stored before they existed",
+		Memory:      rebuildMemoryLedger,
+		Badger:      rebuildBadgerLedger,
+	},
+	{
+		Version:     5,
+		Description: "create the unchecked table",
+		Memory:      createUncheckedTable,
+	},
+	{
+		Version:     6,
+		Description: "resolve the subtype (send, receive, open, change or epoch) of every stored block",
+		Memory:      backfillSubtypes,
+		Badger:      (*badger_backend.BadgerBackend).BackfillSubtypes,
+	},
+	{
+		Version:     7,
+		Description: "drop the hashes of pruned blocks from the chain index",
+		Memory:      trimPrunedChains,
+	},
+}
+
+func checkSchemaVersion(version uint64) error {
+	if version > SCHEMA_VERSION {
+		return fmt.Errorf("ledger is at schema version %d but this build of gnano only supports up to version %d, upgrade gnano to open it", version, SCHEMA_VERSION)
+	}
+
+	return nil
+}
+
+func upgradeMemorySchema(data *memory_backend.DBSchema) error {
+	err := checkSchemaVersion(data.Version)
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range MIGRATIONS {
+		if migration.Version <= data.Version {
+			continue
+		}
+
+		log.Println("Migrating ledger to schema version", migration.Version, "-", migration.Description)
+		if migration.Memory != nil {
+			err = migration.Memory(data)
+			if err != nil {
+				return fmt.Errorf("error migrating ledger to schema version %d: %w", migration.Version, err)
+			}
+		}
+
+		data.Version = migration.Version
+	}
+
+	return nil
+}
+
+// Every step is recorded as soon as it's done, so an interrupted upgrade resumes from the step that didn't finish
+func upgradeBadgerSchema(backend *badger_backend.BadgerBackend) error {
+	version := backend.GetSchemaVersion()
+
+	err := checkSchemaVersion(version)
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range MIGRATIONS {
+		if migration.Version <= version {
+			continue
+		}
+
+		log.Println("Migrating ledger to schema version", migration.Version, "-", migration.Description)
+		if migration.Badger != nil {
+			err = migration.Badger(backend)
+			if err != nil {
+				return fmt.Errorf("error migrating ledger to schema version %d: %w", migration.Version, err)
+			}
+		}
+
+		err = backend.SetSchemaVersion(migration.Version)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Every block stored before confirmation heights existed was cemented, so each account is confirmed up to its frontier
+func backfillConfirmationHeights(data *memory_backend.DBSchema) error {
+	// Ledgers created before schema versions existed may already track them
+	if data.ConfirmationHeights != nil {
+		return nil
+	}
+
+	data.ConfirmationHeights = make(map[string]ledger.ConfirmationHeight, len(data.Accounts))
+	data.CementedCount = 0
+
+	for address, account := range data.Accounts {
+		if account.Frontier == nil || account.Sideband == nil {
+			continue
+		}
+
+		data.ConfirmationHeights[address] = ledger.ConfirmationHeight{
+			Height:   account.Sideband.Height.Uint64(),
+			Frontier: *account.Frontier,
+		}
+
+		data.CementedCount += account.Sideband.Height.Uint64()
+	}
+
+	return nil
+}
+
+func createMissingTables(data *memory_backend.DBSchema) error {
+	if data.Sidebands == nil {
+		data.Sidebands = make(map[string]types.Sideband)
+	}
+
+	if data.Chains == nil {
+		data.Chains = make(map[string][]string)
+	}
+
+	if data.RepWeights == nil {
+		data.RepWeights = make(map[string]types.Amount)
+	}
+
+	if data.Receivables == nil {
+		data.Receivables = make(map[string]map[string]ledger.ReceivableInfo)
+	}
+
+	if data.Pruned == nil {
+		data.Pruned = make(map[string]bool)
+	}
+
+	return nil
+}
+
+// Legacy send blocks used to be stored without their type, every other block type was set when parsing it
+func inferLegacySendTypes(data *memory_backend.DBSchema) error {
+	for hash, block := range data.Blocks {
+		if block.Type == 0 {
+			block.Type = types.BLOCK_TYPE_SEND
+			data.Blocks[hash] = block
+		}
+	}
+
+	return nil
+}
+
+func createUncheckedTable(data *memory_backend.DBSchema) error {
+	if data.Unchecked == nil {
+		data.Unchecked = make(map[string]ledger.UncheckedInfo)
+	}
+
+	return nil
+}
+
+// Blocks whose previous block was pruned are left as unknown, the balance they started from is gone
+func backfillSubtypes(data *memory_backend.DBSchema) error {
+	for hash, sideband := range data.Sidebands {
+		if sideband.Subtype != types.BLOCK_SUBTYPE_UNKNOWN {
+			continue
+		}
+
+		block, found := data.Blocks[hash]
+		if !found {
+			continue
+		}
+
+		previous_balance := types.Amount{}
+		if !block.IsOpenBlock() {
+			previous, found := data.Sidebands[block.Previous.ToHexString()]
+			if !found {
+				continue
+			}
+
+			previous_balance = previous.Balance
+		}
+
+		sideband.Subtype = ledger.ResolveSubtype(&block, previous_balance, sideband.Balance)
+		data.Sidebands[hash] = sideband
+	}
+
+	// Accounts keep a copy of their frontier's sideband
+	for address, account := range data.Accounts {
+		if account.Frontier == nil {
+			continue
+		}
+
+		if sideband, found := data.Sidebands[account.Frontier.ToHexString()]; found {
+			account.Sideband = &sideband
+			data.Accounts[address] = account
+		}
+	}
+
+	return nil
+}
+
+// The badger height index already dropped pruned blocks along with them
+func trimPrunedChains(data *memory_backend.DBSchema) error {
+	if data.ChainBases == nil {
+		data.ChainBases = make(map[string]uint64)
+	}
+
+	for account := range data.Chains {
+		memory_backend.TrimPrunedChain(data, account)
+	}
+
+	return nil
+}
+
+var errFrontierWithoutSideband = errors.New("frontier without a sideband")
+
+// Blocks stored before sidebands existed were accepted without the receivable and weight tables being kept up to date,
+// those ledgers have accounts whose frontier has no sideband
+func hasFrontiersWithoutSidebands(backend DatabaseBackend) (bool, error) {
+	err := backend.ForEachAccount(func(address *types.Address) error {
+		account := backend.GetAccount(address)
+		if account == nil || backend.GetBlockSideband(account.Frontier.Hash) == nil {
+			return errFrontierWithoutSideband
+		}
+
+		return nil
+	})
+
+	if errors.Is(err, errFrontierWithoutSideband) {
+		return true, nil
+	}
+
+	return false, err
+}
+
+// Replays every block into an empty ledger, which derives the sidebands, chains, weights and receivables from scratch.
+// rebuildLedger needs every chain down to its open block, so a pruned ledger can't be rebuilt. Only ledgers written
+// before sidebands existed are rebuilt and pruning needs sidebands, so none of them was pruned.
+func rebuildMemoryLedger(data *memory_backend.DBSchema) error {
+	source := &memory_backend.MemoryBackend{Data: *data}
+
+	outdated, err := hasFrontiersWithoutSidebands(source)
+	if err != nil || !outdated {
+		return err
+	}
+
+	rebuilt := memory_backend.New(nil)
+	err = rebuildLedger(source, rebuilt)
+	if err != nil {
+		return fmt.Errorf("error rebuilding the ledger: %w", err)
+	}
+
+	*data = rebuilt.Data
+
+	return nil
+}
+
+// The blocks are rebuilt into a new badger ledger next to this one, which only replaces it once it's complete.
+// A node that stops halfway through starts the rebuild over, or finishes the swap if it was the only step left.
+// Like rebuildMemoryLedger, this never meets a pruned ledger.
+func rebuildBadgerLedger(backend *badger_backend.BadgerBackend) error {
+	outdated, err := hasFrontiersWithoutSidebands(backend)
+	if err != nil || !outdated {
+		return err
+	}
+
+	rebuilt, err := backend.OpenRebuild()
+	if err != nil {
+		return err
+	}
+
+	err = rebuildLedger(backend, rebuilt)
+	if err != nil {
+		rebuilt.Cleanup()
+
+		return fmt.Errorf("error rebuilding the ledger, it was left untouched: %w", err)
+	}
+
+	return backend.ReplaceWith(rebuilt)
+}
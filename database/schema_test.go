@@ -0,0 +1,199 @@
+package database
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	badger_backend "github.com/Shryder/gnano/database/badger"
+	"github.com/Shryder/gnano/database/ledger"
+	memory_backend "github.com/Shryder/gnano/database/memory"
+	"github.com/Shryder/gnano/types"
+)
+
+// Badger ledger at schema version 2 whose blocks have no sidebands, like the ones stored before sidebands existed
+func newOutdatedBadgerLedger(t *testing.T, path string) *badger_backend.BadgerBackend {
+	backend, err := badger_backend.Initialize(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = MigrateLedger(newTestLedger(t), backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = backend.Badger.DropPrefix([]byte{badger_backend.PREFIX_SIDEBAND})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = backend.SetSchemaVersion(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return backend
+}
+
+func TestRebuildBadgerLedger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Badger")
+
+	backend := newOutdatedBadgerLedger(t, path)
+	defer func() { backend.Cleanup() }()
+
+	err := upgradeBadgerSchema(backend)
+	if err != nil {
+		t.Fatal("upgrade failed:", err)
+	}
+
+	if backend.GetSchemaVersion() != SCHEMA_VERSION {
+		t.Errorf("ledger is at schema version %d, want %d", backend.GetSchemaVersion(), SCHEMA_VERSION)
+	}
+
+	if backend.GetBlockCount() != 7 || backend.GetCementedCount() != 4 {
+		t.Errorf("rebuilt ledger has %d blocks (%d cemented), want 7 (4 cemented)", backend.GetBlockCount(), backend.GetCementedCount())
+	}
+
+	outdated, err := hasFrontiersWithoutSidebands(backend)
+	if err != nil || outdated {
+		t.Errorf("rebuilt ledger still has frontiers without sidebands (error %v)", err)
+	}
+
+	// Only the live ledger is left next to it
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 {
+		t.Errorf("found %d directories after the rebuild, want only the ledger", len(entries))
+	}
+
+	// The swapped in ledger is the one that's opened from now on
+	backend.Cleanup()
+
+	backend, err = badger_backend.Initialize(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if backend.GetBlockCount() != 7 || backend.GetSchemaVersion() != SCHEMA_VERSION {
+		t.Errorf("reopened ledger has %d blocks at schema version %d, want 7 at %d", backend.GetBlockCount(), backend.GetSchemaVersion(), SCHEMA_VERSION)
+	}
+}
+
+// A node stopping between moving the live ledger out of the way and moving the rebuilt one in finishes the swap on start
+func TestInterruptedBadgerRebuildSwap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Badger")
+
+	outdated := newOutdatedBadgerLedger(t, path)
+	outdated.Cleanup()
+
+	rebuilt, err := badger_backend.Initialize(path+badger_backend.REBUILD_STAGING_SUFFIX, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = MigrateLedger(newTestLedger(t), rebuilt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rebuilt.Cleanup()
+
+	for _, rename := range [][2]string{
+		{path + badger_backend.REBUILD_STAGING_SUFFIX, path + badger_backend.REBUILD_COMPLETE_SUFFIX},
+		{path, path + badger_backend.REBUILD_REPLACED_SUFFIX},
+	} {
+		err = os.Rename(rename[0], rename[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	backend, err := badger_backend.Initialize(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer backend.Cleanup()
+
+	if backend.GetBlockCount() != 7 || backend.GetBlockSideband(&types.Hash{0x31}) == nil {
+		t.Error("the rebuilt ledger wasn't swapped in")
+	}
+
+	for _, suffix := range []string{badger_backend.REBUILD_COMPLETE_SUFFIX, badger_backend.REBUILD_REPLACED_SUFFIX} {
+		_, err = os.Stat(path + suffix)
+		if !os.IsNotExist(err) {
+			t.Errorf("%s was left behind", path+suffix)
+		}
+	}
+}
+
+// JSON ledger as the first release wrote it. Genesis (0xff) sent 100 raw to alice with a legacy send (0x10) that was
+// stored without its type, alice opened with it (0x20) and then received a send that was never stored (0x21).
+const baselineLedger = `{
+	"nodes": {},
+	"blocks": {
+		"ff00000000000000000000000000000000000000000000000000000000000000": {"type":4,"hash":"ff00000000000000000000000000000000000000000000000000000000000000","previous":"0000000000000000000000000000000000000000000000000000000000000000","account":"0100000000000000000000000000000000000000000000000000000000000000","representative":"0100000000000000000000000000000000000000000000000000000000000000","balance":null,"link":"0100000000000000000000000000000000000000000000000000000000000000","signature":"00000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000","work":"0000000000000000"},
+		"1000000000000000000000000000000000000000000000000000000000000000": {"type":0,"hash":"1000000000000000000000000000000000000000000000000000000000000000","previous":"ff00000000000000000000000000000000000000000000000000000000000000","account":null,"representative":null,"balance":"340282366920938463463374607431768211355","link":"0200000000000000000000000000000000000000000000000000000000000000","signature":"00000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000","work":"0000000000000000"},
+		"2000000000000000000000000000000000000000000000000000000000000000": {"type":4,"hash":"2000000000000000000000000000000000000000000000000000000000000000","previous":"0000000000000000000000000000000000000000000000000000000000000000","account":"0200000000000000000000000000000000000000000000000000000000000000","representative":"0200000000000000000000000000000000000000000000000000000000000000","balance":null,"link":"1000000000000000000000000000000000000000000000000000000000000000","signature":"00000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000","work":"0000000000000000"},
+		"2100000000000000000000000000000000000000000000000000000000000000": {"type":3,"hash":"2100000000000000000000000000000000000000000000000000000000000000","previous":"2000000000000000000000000000000000000000000000000000000000000000","account":null,"representative":null,"balance":null,"link":"9900000000000000000000000000000000000000000000000000000000000000","signature":"00000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000","work":"0000000000000000"}
+	},
+	"accounts": {
+		"0100000000000000000000000000000000000000000000000000000000000000": {"frontier":"1000000000000000000000000000000000000000000000000000000000000000","sideband":{"height":2,"timestamp":1650000000}},
+		"0200000000000000000000000000000000000000000000000000000000000000": {"frontier":"2100000000000000000000000000000000000000000000000000000000000000","sideband":{"height":2,"timestamp":1650000000}}
+	},
+	"weights": {}
+}`
+
+func TestRebuildBaselineLedger(t *testing.T) {
+	ledger.SetGenesis(&types.Block{Hash: &types.Hash{0xff}})
+
+	var data memory_backend.DBSchema
+	err := json.Unmarshal([]byte(baselineLedger), &data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = upgradeMemorySchema(&data)
+	if err != nil {
+		t.Fatal("upgrade failed:", err)
+	}
+
+	if data.Version != SCHEMA_VERSION {
+		t.Errorf("ledger is at schema version %d, want %d", data.Version, SCHEMA_VERSION)
+	}
+
+	backend := &memory_backend.MemoryBackend{Data: data}
+
+	send := backend.GetBlock(&types.Hash{0x10})
+	if send == nil || send.Type != types.BLOCK_TYPE_SEND {
+		t.Fatalf("legacy send is %+v, want it kept as a send", send)
+	}
+
+	sideband := backend.GetBlockSideband(&types.Hash{0x10})
+	if sideband == nil || sideband.Subtype != types.BLOCK_SUBTYPE_SEND || *sideband.Account != genesisAccount {
+		t.Errorf("legacy send has sideband %+v, want a send of the genesis account", sideband)
+	}
+
+	// The receive of the missing send is left out to be synced again, alice's open block stays
+	if backend.GetBlock(&types.Hash{0x21}) != nil {
+		t.Error("receive of a send that isn't in the ledger was kept")
+	}
+
+	if backend.GetBlockCount() != 3 || backend.GetAccountCount() != 2 || backend.GetCementedCount() != 3 {
+		t.Errorf("rebuilt ledger has %d blocks (%d cemented) in %d accounts, want 3 (3 cemented) in 2", backend.GetBlockCount(), backend.GetCementedCount(), backend.GetAccountCount())
+	}
+
+	account := backend.GetAccount(&alice)
+	if account == nil || *account.Frontier.Hash != (types.Hash{0x20}) || account.Sideband.Balance != (types.Amount{Lo: 100}) {
+		t.Errorf("alice's account is %+v, want it opened with the 100 raw genesis sent", account)
+	}
+
+	if len(backend.GetReceivables(&alice)) != 0 {
+		t.Error("the send alice opened with is receivable again")
+	}
+}
@@ -0,0 +1,417 @@
+package database
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/Shryder/gnano/database/ledger"
+	"github.com/Shryder/gnano/p2p/packets"
+	"github.com/Shryder/gnano/types"
+)
+
+// Snapshot layout, integers are big endian:
+//
+//	magic (8 bytes) | version (2 bytes)
+//	blocks, each one before the blocks depending on it: block type (1 byte) | block in its wire format, ended by a BLOCK_TYPE_NOT_A_BLOCK byte
+//	accounts: 1 | public key (32) | frontier (32) | height (8) | confirmation height (8) | cemented frontier (32), ended by 0
+//	bootstrap weights: 1 | public key (32) | weight (16), ended by 0
+//	representative weights: 1 | public key (32) | weight (16), ended by 0
+//	sha256 of everything above (32 bytes)
+const (
+	SNAPSHOT_MAGIC   = "GNANOSNP"
+	SNAPSHOT_VERSION = uint16(1)
+
+	SNAPSHOT_ENTRY     byte = 1
+	SNAPSHOT_TABLE_END byte = 0
+)
+
+// Serves the blocks of the ledger being exported to ledger.Cement, whose confirmation heights are the heights exported so far.
+// Cementing an account's frontier on top of it yields the blocks that weren't exported yet, sources first.
+type exportView struct {
+	backend DatabaseBackend
+	heights map[types.Address]ledger.ConfirmationHeight
+}
+
+func (view *exportView) GetBlock(hash *types.Hash) (*types.Block, error) {
+	return view.backend.GetBlock(hash), nil
+}
+
+func (view *exportView) GetBlockSideband(hash *types.Hash) (*types.Sideband, error) {
+	return view.backend.GetBlockSideband(hash), nil
+}
+
+func (view *exportView) GetConfirmationHeight(address *types.Address) (*ledger.ConfirmationHeight, error) {
+	height, found := view.heights[*address]
+	if !found {
+		return nil, nil
+	}
+
+	return &height, nil
+}
+
+// Pruned ledgers can't be exported, every block has to be written before the blocks that depend on it
+func (view *exportView) IsBlockPruned(hash *types.Hash) (bool, error) {
+	return false, nil
+}
+
+// Writes every block, account and weight of the backend to output, the result can be imported into any backend with ImportLedger
+func ExportLedger(backend DatabaseBackend, output io.Writer) error {
+	if pruned := backend.GetPrunedCount(); pruned > 0 {
+		return fmt.Errorf("refusing to export a pruned ledger, %d blocks were discarded", pruned)
+	}
+
+	checksum := sha256.New()
+	writer := bufio.NewWriter(io.MultiWriter(output, checksum))
+
+	writer.WriteString(SNAPSHOT_MAGIC)
+	binary.Write(writer, binary.BigEndian, SNAPSHOT_VERSION)
+
+	view := &exportView{backend: backend, heights: make(map[types.Address]ledger.ConfirmationHeight)}
+	blocks_count := 0
+	err := backend.ForEachAccount(func(address *types.Address) error {
+		account := backend.GetAccount(address)
+		if account == nil {
+			return fmt.Errorf("account %s has no frontier", address.ToNanoAddress())
+		}
+
+		pending, err := ledger.Cement(view, account.Frontier.Hash)
+		if err != nil {
+			return fmt.Errorf("error ordering the blocks of %s: %w", address.ToNanoAddress(), err)
+		}
+
+		for _, hash := range pending.Blocks {
+			err = writeSnapshotBlock(writer, backend.GetBlock(hash))
+			if err != nil {
+				return fmt.Errorf("error exporting block %s: %w", hash.ToHexString(), err)
+			}
+		}
+
+		for account, height := range pending.Heights {
+			view.heights[account] = height
+		}
+
+		blocks_count += len(pending.Blocks)
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	writer.WriteByte(packets.BLOCK_TYPE_NOT_A_BLOCK)
+
+	accounts_count := 0
+	err = backend.ForEachAccount(func(address *types.Address) error {
+		account := backend.GetAccount(address)
+		if account == nil {
+			return fmt.Errorf("account %s has no frontier", address.ToNanoAddress())
+		}
+
+		cemented := ledger.ConfirmationHeight{}
+		if confirmation_height := backend.GetConfirmationHeight(address); confirmation_height != nil {
+			cemented = *confirmation_height
+		}
+
+		writer.WriteByte(SNAPSHOT_ENTRY)
+		writer.Write(address[:])
+		writer.Write(account.Frontier.Hash[:])
+		binary.Write(writer, binary.BigEndian, account.Sideband.Height.Uint64())
+		binary.Write(writer, binary.BigEndian, cemented.Height)
+		writer.Write(cemented.Frontier[:])
+
+		accounts_count++
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	writer.WriteByte(SNAPSHOT_TABLE_END)
+
+	for _, for_each := range []func(func(*types.Address, types.Amount) error) error{backend.ForEachBootstrapWeight, backend.ForEachVotingWeight} {
+		err = for_each(func(address *types.Address, weight types.Amount) error {
+			writer.WriteByte(SNAPSHOT_ENTRY)
+			writer.Write(address[:])
+			_, err := writer.Write(weight.BytesBE())
+
+			return err
+		})
+
+		if err != nil {
+			return err
+		}
+
+		writer.WriteByte(SNAPSHOT_TABLE_END)
+	}
+
+	err = writer.Flush()
+	if err != nil {
+		return err
+	}
+
+	_, err = output.Write(checksum.Sum(nil))
+	if err != nil {
+		return err
+	}
+
+	log.Println("Exported", blocks_count, "blocks and", accounts_count, "accounts from the", backend.BackendName(), "ledger")
+
+	return nil
+}
+
+func writeSnapshotBlock(writer *bufio.Writer, block *types.Block) error {
+	if block == nil {
+		return errors.New("block not found")
+	}
+
+	data, err := packets.SerializeBlock(block)
+	if err != nil {
+		return err
+	}
+
+	writer.WriteByte(block.Type)
+	_, err = writer.Write(data)
+
+	return err
+}
+
+// Checks the trailing checksum and returns the size of the snapshot without it
+func verifySnapshotChecksum(input io.ReadSeeker) (int64, error) {
+	size, err := input.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+
+	if size < int64(len(SNAPSHOT_MAGIC)+2+sha256.Size) {
+		return 0, errors.New("snapshot is too small")
+	}
+
+	_, err = input.Seek(0, io.SeekStart)
+	if err != nil {
+		return 0, err
+	}
+
+	checksum := sha256.New()
+	_, err = io.CopyN(checksum, input, size-sha256.Size)
+	if err != nil {
+		return 0, err
+	}
+
+	expected := make([]byte, sha256.Size)
+	_, err = io.ReadFull(input, expected)
+	if err != nil {
+		return 0, err
+	}
+
+	if !bytes.Equal(checksum.Sum(nil), expected) {
+		return 0, errors.New("snapshot checksum mismatch, the file is corrupted")
+	}
+
+	_, err = input.Seek(0, io.SeekStart)
+
+	return size - sha256.Size, err
+}
+
+// Reads the 1 byte marker in front of every table entry, false once the table ended
+func readSnapshotEntryMarker(reader *bufio.Reader) (bool, error) {
+	marker, err := reader.ReadByte()
+	if err != nil {
+		return false, err
+	}
+
+	switch marker {
+	case SNAPSHOT_ENTRY:
+		return true, nil
+	case SNAPSHOT_TABLE_END:
+		return false, nil
+	}
+
+	return false, fmt.Errorf("invalid table entry marker %d", marker)
+}
+
+// Loads a snapshot written by ExportLedger into an empty backend. The checksum is verified before anything is written,
+// then the blocks go through the ledger rules like any other block and the resulting accounts and weights are compared to the snapshot's.
+func ImportLedger(backend DatabaseBackend, input io.ReadSeeker) error {
+	if backend.GetBlockCount() != 0 || backend.GetAccountCount() != 0 {
+		return fmt.Errorf("refusing to import into %s backend because it already has %d blocks and %d accounts", backend.BackendName(), backend.GetBlockCount(), backend.GetAccountCount())
+	}
+
+	size, err := verifySnapshotChecksum(input)
+	if err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(io.LimitReader(input, size))
+
+	header := make([]byte, len(SNAPSHOT_MAGIC)+2)
+	_, err = io.ReadFull(reader, header)
+	if err != nil {
+		return err
+	}
+
+	if string(header[:len(SNAPSHOT_MAGIC)]) != SNAPSHOT_MAGIC {
+		return errors.New("not a gnano ledger snapshot")
+	}
+
+	version := binary.BigEndian.Uint16(header[len(SNAPSHOT_MAGIC):])
+	if version > SNAPSHOT_VERSION {
+		return fmt.Errorf("snapshot version %d is newer than the supported version %d", version, SNAPSHOT_VERSION)
+	}
+
+	log.Println("Importing ledger snapshot version", version, "into", backend.BackendName())
+
+	blocks_count, err := importSnapshotBlocks(backend, reader)
+	if err != nil {
+		return fmt.Errorf("error importing blocks: %w", err)
+	}
+
+	accounts_count, err := importSnapshotAccounts(backend, reader)
+	if err != nil {
+		return fmt.Errorf("error importing accounts: %w", err)
+	}
+
+	err = readSnapshotWeights(reader, backend.PutBootstrapWeight)
+	if err != nil {
+		return fmt.Errorf("error importing bootstrap weights: %w", err)
+	}
+
+	err = readSnapshotWeights(reader, func(address *types.Address, weight types.Amount) error {
+		if backend.GetVotingWeight(address).Cmp(weight) != 0 {
+			return fmt.Errorf("voting weight of %s doesn't match the snapshot's", address.ToNanoAddress())
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return fmt.Errorf("error checking representative weights: %w", err)
+	}
+
+	if backend.GetBlockCount() != blocks_count || backend.GetAccountCount() != accounts_count {
+		return fmt.Errorf("imported %d blocks and %d accounts but the snapshot has %d blocks and %d accounts", backend.GetBlockCount(), backend.GetAccountCount(), blocks_count, accounts_count)
+	}
+
+	log.Println("Imported", blocks_count, "blocks (", backend.GetCementedCount(), "cemented ) and", accounts_count, "accounts")
+
+	return nil
+}
+
+func importSnapshotBlocks(backend DatabaseBackend, reader *bufio.Reader) (uint64, error) {
+	count := uint64(0)
+	batch := make([]*types.Block, 0, MIGRATION_BATCH_SIZE)
+	for {
+		block_type, err := reader.ReadByte()
+		if err != nil {
+			return count, err
+		}
+
+		if block_type == packets.BLOCK_TYPE_NOT_A_BLOCK {
+			break
+		}
+
+		data := make([]byte, packets.BlockType(block_type).Size())
+		_, err = io.ReadFull(reader, data)
+		if err != nil {
+			return count, err
+		}
+
+		block, err := packets.ParseBlock(packets.BlockType(block_type), data)
+		if err != nil {
+			return count, err
+		}
+
+		batch = append(batch, block)
+		if len(batch) == MIGRATION_BATCH_SIZE {
+			err = backend.PutBlocks(batch)
+			if err != nil {
+				return count, err
+			}
+
+			count += uint64(len(batch))
+			batch = batch[:0]
+
+			if (count/MIGRATION_BATCH_SIZE)%100 == 0 {
+				log.Println("Imported", count, "blocks so far")
+			}
+		}
+	}
+
+	err := backend.PutBlocks(batch)
+	if err != nil {
+		return count, err
+	}
+
+	return count + uint64(len(batch)), nil
+}
+
+// Checks every account's frontier against the one the ledger rules came up with and restores its confirmation height
+func importSnapshotAccounts(backend DatabaseBackend, reader *bufio.Reader) (uint64, error) {
+	count := uint64(0)
+	entry := make([]byte, 32+32+8+8+32)
+	for {
+		has_entry, err := readSnapshotEntryMarker(reader)
+		if err != nil || !has_entry {
+			return count, err
+		}
+
+		_, err = io.ReadFull(reader, entry)
+		if err != nil {
+			return count, err
+		}
+
+		var address types.Address
+		var frontier types.Hash
+		var cemented_frontier types.Hash
+		copy(address[:], entry[0:32])
+		copy(frontier[:], entry[32:64])
+		height := binary.BigEndian.Uint64(entry[64:72])
+		cemented_height := binary.BigEndian.Uint64(entry[72:80])
+		copy(cemented_frontier[:], entry[80:112])
+
+		account := backend.GetAccount(&address)
+		if account == nil || *account.Frontier.Hash != frontier || account.Sideband.Height.Uint64() != height {
+			return count, fmt.Errorf("account %s doesn't match the snapshot, expected frontier %s at height %d", address.ToNanoAddress(), frontier.ToHexString(), height)
+		}
+
+		if cemented_height > 0 {
+			_, err = backend.CementBlock(&cemented_frontier)
+			if err != nil {
+				return count, err
+			}
+		}
+
+		count++
+	}
+}
+
+func readSnapshotWeights(reader *bufio.Reader, callback func(address *types.Address, weight types.Amount) error) error {
+	entry := make([]byte, 32+16)
+	for {
+		has_entry, err := readSnapshotEntryMarker(reader)
+		if err != nil || !has_entry {
+			return err
+		}
+
+		_, err = io.ReadFull(reader, entry)
+		if err != nil {
+			return err
+		}
+
+		var address types.Address
+		copy(address[:], entry[0:32])
+
+		err = callback(&address, types.AmountFromBytesBE(entry[32:48]))
+		if err != nil {
+			return err
+		}
+	}
+}
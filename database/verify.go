@@ -0,0 +1,275 @@
+package database
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/Shryder/gnano/database/ledger"
+	"github.com/Shryder/gnano/p2p/packets"
+	"github.com/Shryder/gnano/types"
+	"github.com/shryder/ed25519-blake2b"
+)
+
+// Inconsistency found while verifying a ledger
+type VerificationIssue struct {
+	Account *types.Address // nil for issues about the whole ledger
+	Hash    *types.Hash    // nil for issues that aren't about a specific block
+	Problem string
+}
+
+func (issue *VerificationIssue) String() string {
+	if issue.Account == nil {
+		return issue.Problem
+	}
+
+	if issue.Hash == nil {
+		return fmt.Sprintf("account %s: %s", issue.Account.ToNanoAddress(), issue.Problem)
+	}
+
+	return fmt.Sprintf("account %s block %s: %s", issue.Account.ToNanoAddress(), issue.Hash.ToHexString(), issue.Problem)
+}
+
+type VerificationReport struct {
+	Accounts uint64
+	Blocks   uint64
+	Cemented uint64
+	Issues   []*VerificationIssue
+}
+
+type ledgerVerifier struct {
+	backend DatabaseBackend
+	genesis *types.Block
+	report  *VerificationReport
+}
+
+func (verifier *ledgerVerifier) addIssue(account *types.Address, hash *types.Hash, format string, args ...interface{}) {
+	issue := &VerificationIssue{Account: account, Hash: hash, Problem: fmt.Sprintf(format, args...)}
+	log.Println("Ledger inconsistency:", issue.String())
+
+	verifier.report.Issues = append(verifier.report.Issues, issue)
+}
+
+// Walks every account chain from its frontier down to its open block (or its first pruned block), checking each block's hash, signature and sideband
+// along with the account's frontier, height index and confirmation height. The totals are compared with the backend's counters.
+// genesis is used to check epoch block signatures.
+func VerifyLedger(backend DatabaseBackend, genesis *types.Block) (*VerificationReport, error) {
+	verifier := &ledgerVerifier{
+		backend: backend,
+		genesis: genesis,
+		report:  &VerificationReport{Issues: make([]*VerificationIssue, 0)},
+	}
+
+	log.Println("Verifying", backend.BackendName(), "ledger")
+
+	err := backend.ForEachAccount(func(address *types.Address) error {
+		verifier.verifyAccount(address)
+
+		verifier.report.Accounts++
+		if verifier.report.Accounts%10_000 == 0 {
+			log.Println("Verified", verifier.report.Accounts, "accounts so far")
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	verifier.verifyCounters()
+
+	return verifier.report, nil
+}
+
+func (verifier *ledgerVerifier) verifyCounters() {
+	report := verifier.report
+
+	if count := verifier.backend.GetAccountCount(); count != report.Accounts {
+		verifier.addIssue(nil, nil, "backend reports %d accounts but %d were walked", count, report.Accounts)
+	}
+
+	if count := verifier.backend.GetBlockCount(); count != report.Blocks {
+		verifier.addIssue(nil, nil, "backend reports %d blocks but %d are reachable from account frontiers", count, report.Blocks)
+	}
+
+	if count := verifier.backend.GetCementedCount(); count != report.Cemented {
+		verifier.addIssue(nil, nil, "backend reports %d cemented blocks but the confirmation heights add up to %d", count, report.Cemented)
+	}
+}
+
+func (verifier *ledgerVerifier) verifyAccount(address *types.Address) {
+	account := verifier.backend.GetAccount(address)
+	if account == nil {
+		verifier.addIssue(address, nil, "account is listed but has no entry or its frontier block is missing")
+		return
+	}
+
+	if account.Sideband.Height == nil || account.Sideband.Height.Sign() <= 0 {
+		verifier.addIssue(address, account.Frontier.Hash, "account has no valid height")
+		return
+	}
+
+	frontier_height := account.Sideband.Height.Uint64()
+
+	var successor *types.Hash
+	cursor := account.Frontier.Hash
+	height := frontier_height
+	hashes_by_height := make(map[uint64]types.Hash, frontier_height)
+	for {
+		block := verifier.backend.GetBlock(cursor)
+		if block == nil && verifier.backend.IsBlockPruned(cursor) {
+			// The rest of the chain was pruned along with it
+			verifier.verifyPrunedBlock(address, cursor, height)
+			break
+		}
+
+		if block == nil {
+			verifier.addIssue(address, cursor, "block at height %d is missing", height)
+			break
+		}
+
+		verifier.verifyBlock(address, cursor, block)
+		verifier.verifySideband(address, block, height, successor)
+
+		verifier.report.Blocks++
+		hashes_by_height[height] = *cursor
+
+		if block.IsOpenBlock() {
+			if height != 1 {
+				verifier.addIssue(address, cursor, "open block is at height %d instead of 1", height)
+			}
+
+			break
+		}
+
+		if height == 1 {
+			verifier.addIssue(address, cursor, "chain continues below height 1 to %s", block.Previous.ToHexString())
+			break
+		}
+
+		successor = cursor
+		cursor = block.Previous
+		height--
+	}
+
+	verifier.verifyConfirmationHeight(address, frontier_height, hashes_by_height)
+}
+
+func (verifier *ledgerVerifier) verifyBlock(address *types.Address, hash *types.Hash, block *types.Block) {
+	if block.Hash == nil || *block.Hash != *hash {
+		verifier.addIssue(address, hash, "block is stored under a different hash than its own")
+	}
+
+	if block.Account != nil && *block.Account != *address {
+		verifier.addIssue(address, hash, "block belongs to account %s", block.Account.ToNanoAddress())
+	}
+
+	computed, err := packets.HashBlock(block)
+	if err != nil {
+		verifier.addIssue(address, hash, "hash can't be computed: %s", err)
+		return
+	}
+
+	if *computed != *hash {
+		verifier.addIssue(address, hash, "contents hash to %s", computed.ToHexString())
+		return
+	}
+
+	if block.Signature == nil {
+		verifier.addIssue(address, hash, "block has no signature")
+		return
+	}
+
+	if ed25519.Verify(address.ToPublicKey(), hash[:], block.Signature[:]) {
+		return
+	}
+
+	// Epoch blocks are signed by the epoch signer, which is the genesis account
+	if _, is_epoch := ledger.EpochOfLink(block.Link); is_epoch && block.Type == types.BLOCK_TYPE_STATE && verifier.genesis != nil {
+		if ed25519.Verify(verifier.genesis.Account.ToPublicKey(), hash[:], block.Signature[:]) {
+			return
+		}
+	}
+
+	verifier.addIssue(address, hash, "invalid signature")
+}
+
+func (verifier *ledgerVerifier) verifySideband(address *types.Address, block *types.Block, height uint64, successor *types.Hash) {
+	sideband := verifier.backend.GetBlockSideband(block.Hash)
+	if sideband == nil {
+		verifier.addIssue(address, block.Hash, "block has no sideband")
+		return
+	}
+
+	if sideband.Height == nil || sideband.Height.Uint64() != height {
+		verifier.addIssue(address, block.Hash, "sideband height is %v but the block is at height %d", sideband.Height, height)
+	}
+
+	if sideband.Account == nil || *sideband.Account != *address {
+		verifier.addIssue(address, block.Hash, "sideband doesn't point to the account")
+	}
+
+	if successor == nil && sideband.Successor != nil {
+		verifier.addIssue(address, block.Hash, "frontier has successor %s", sideband.Successor.ToHexString())
+	}
+
+	if successor != nil && (sideband.Successor == nil || *sideband.Successor != *successor) {
+		verifier.addIssue(address, block.Hash, "sideband successor doesn't match the next block %s", successor.ToHexString())
+	}
+
+	if block.Balance != nil && (block.Type == types.BLOCK_TYPE_STATE || block.Type == types.BLOCK_TYPE_SEND) && block.Balance.Cmp(sideband.Balance) != 0 {
+		verifier.addIssue(address, block.Hash, "sideband balance doesn't match the block's balance")
+	}
+
+	verifier.verifySubtype(block, sideband)
+
+	indexed := verifier.backend.GetBlockAtHeight(address, height)
+	if indexed == nil || *indexed.Hash != *block.Hash {
+		verifier.addIssue(address, block.Hash, "height index doesn't point to the block at height %d", height)
+	}
+}
+
+// Blocks whose previous block was pruned can't be checked, their previous balance is gone
+func (verifier *ledgerVerifier) verifySubtype(block *types.Block, sideband *types.Sideband) {
+	previous_balance := types.Amount{}
+	if !block.IsOpenBlock() {
+		previous := verifier.backend.GetBlockSideband(block.Previous)
+		if previous == nil {
+			return
+		}
+
+		previous_balance = previous.Balance
+	}
+
+	subtype := ledger.ResolveSubtype(block, previous_balance, sideband.Balance)
+	if sideband.Subtype != subtype {
+		verifier.addIssue(sideband.Account, block.Hash, "sideband subtype is %s but the block is a %s", sideband.Subtype.String(), subtype.String())
+	}
+}
+
+// Only cemented blocks can be pruned
+func (verifier *ledgerVerifier) verifyPrunedBlock(address *types.Address, hash *types.Hash, height uint64) {
+	confirmation_height := verifier.backend.GetConfirmationHeight(address)
+	if confirmation_height == nil || confirmation_height.Height < height {
+		verifier.addIssue(address, hash, "block at height %d was pruned before being cemented", height)
+	}
+}
+
+func (verifier *ledgerVerifier) verifyConfirmationHeight(address *types.Address, frontier_height uint64, hashes_by_height map[uint64]types.Hash) {
+	confirmation_height := verifier.backend.GetConfirmationHeight(address)
+	if confirmation_height == nil {
+		return
+	}
+
+	verifier.report.Cemented += confirmation_height.Height
+
+	if confirmation_height.Height > frontier_height {
+		verifier.addIssue(address, &confirmation_height.Frontier, "confirmation height %d is above the frontier's height %d", confirmation_height.Height, frontier_height)
+		return
+	}
+
+	hash, found := hashes_by_height[confirmation_height.Height]
+	if found && hash != confirmation_height.Frontier {
+		verifier.addIssue(address, &confirmation_height.Frontier, "confirmation height %d points to a block that isn't at that height (%s is)", confirmation_height.Height, hash.ToHexString())
+	}
+}
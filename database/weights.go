@@ -0,0 +1,32 @@
+package database
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+
+	"github.com/Shryder/gnano/types"
+)
+
+// Loads weights.json from the current working directory, used to seed the voting weights of a freshly created ledger
+func LoadInitialWeights() (map[string]types.Amount, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+
+	path := path.Join(cwd, "weights.json")
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	initialWeightsRaw := make(map[string]types.Amount)
+	err = json.Unmarshal(contents, &initialWeightsRaw)
+	if err != nil {
+		return nil, err
+	}
+
+	return initialWeightsRaw, nil
+}
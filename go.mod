@@ -1,11 +1,19 @@
 module github.com/Shryder/gnano
 
-go 1.17
+go 1.19
+
+require (
+	github.com/dgraph-io/badger/v3 v3.2103.2
+	github.com/gorilla/mux v1.8.0
+	github.com/naoina/toml v0.1.1
+	github.com/shryder/ed25519-blake2b v0.0.0-20220919005731-4c0874d97666
+	golang.org/x/crypto v0.0.0-20220829220503-c86fa9a7ed90
+	lukechampine.com/uint128 v1.2.0
+)
 
 require (
 	github.com/cespare/xxhash v1.1.0 // indirect
 	github.com/cespare/xxhash/v2 v2.1.1 // indirect
-	github.com/dgraph-io/badger/v3 v3.2103.2 // indirect
 	github.com/dgraph-io/ristretto v0.1.0 // indirect
 	github.com/dustin/go-humanize v1.0.0 // indirect
 	github.com/gogo/protobuf v1.3.2 // indirect
@@ -14,15 +22,10 @@ require (
 	github.com/golang/protobuf v1.3.1 // indirect
 	github.com/golang/snappy v0.0.3 // indirect
 	github.com/google/flatbuffers v1.12.1 // indirect
-	github.com/gorilla/mux v1.8.0 // indirect
 	github.com/klauspost/compress v1.12.3 // indirect
 	github.com/naoina/go-stringutil v0.1.0 // indirect
-	github.com/naoina/toml v0.1.1 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
-	github.com/shryder/ed25519-blake2b v0.0.0-20220919005731-4c0874d97666 // indirect
 	go.opencensus.io v0.22.5 // indirect
-	golang.org/x/crypto v0.0.0-20220829220503-c86fa9a7ed90 // indirect
 	golang.org/x/net v0.0.0-20211112202133-69e39bad7dc2 // indirect
 	golang.org/x/sys v0.0.0-20210615035016-665e8c7367d1 // indirect
-	lukechampine.com/uint128 v1.2.0 // indirect
 )
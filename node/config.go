@@ -10,16 +10,11 @@ type IPCConfig struct {
 	Path string
 }
 
-type TxPoolConfig struct {
-	MaxUncheckedCount uint
-	UncheckedLifetime uint
-}
-
 type Config struct {
 	Nano     p2p.Config
 	HTTP     rpc.HTTPConfig
 	WS       rpc.WSConfig
 	IPC      IPCConfig
-	TxPool   TxPoolConfig
+	TxPool   p2p.TxPoolConfig
 	Database database.Config
 }
@@ -20,7 +20,7 @@ type Node struct {
 func New(cfg *Config, genesisBlock *types.Block) (*Node, error) {
 	node := Node{
 		http:     rpc.NewHTTPRPCServer(&cfg.HTTP),
-		p2p:      p2p.New(&cfg.Nano, genesisBlock),
+		p2p:      p2p.New(&cfg.Nano, &cfg.TxPool, genesisBlock),
 		database: database.New(&cfg.Database),
 
 		StopChannel: make(chan bool),
@@ -48,11 +48,14 @@ func (node *Node) Start() {
 	<-node.StopChannel
 }
 
+// Start returns once everything is saved and closed, so the process can exit right after it
 func (node *Node) Cleanup() {
-	node.StopChannel <- true
+	node.p2p.Cleanup()
 
 	err := node.database.Cleanup()
 	if err != nil {
 		log.Println("Error closing database:", err)
 	}
+
+	node.StopChannel <- true
 }
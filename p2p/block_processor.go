@@ -0,0 +1,380 @@
+package p2p
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/Shryder/gnano/database/ledger"
+	"github.com/Shryder/gnano/types"
+)
+
+// Outcome of checking an incoming block against the ledger and the unconfirmed blocks it builds on
+type BlockStatus byte
+
+const (
+	BLOCK_PROGRESS                BlockStatus = iota // Valid on top of its account's latest block
+	BLOCK_FORK                                       // Another block with the same root was seen first
+	BLOCK_GAP_PREVIOUS                               // Previous block is unknown, held until it arrives
+	BLOCK_GAP_SOURCE                                 // Send being received is unknown, held until it arrives
+	BLOCK_OLD                                        // Already in the ledger or the unchecked table
+	BLOCK_BAD_SIGNATURE                              // Not signed by its account, or by the epoch signer for epoch blocks
+	BLOCK_NEGATIVE_SPEND                             // Send block with a higher balance than its account
+	BLOCK_UNRECEIVABLE                               // Receives a send that isn't for this account or was already received
+	BLOCK_BALANCE_MISMATCH                           // Received amount isn't the amount that was sent, or an epoch block changes the balance
+	BLOCK_REPRESENTATIVE_MISMATCH                    // Epoch block changes the representative
+	BLOCK_POSITION                                   // Legacy block following a state block
+	BLOCK_INVALID                                    // Broken in any other way, an unknown block type for instance
+
+	BLOCK_STATUS_COUNT
+)
+
+var blockStatusNames = [BLOCK_STATUS_COUNT]string{
+	BLOCK_PROGRESS:                "progress",
+	BLOCK_FORK:                    "fork",
+	BLOCK_GAP_PREVIOUS:            "gap_previous",
+	BLOCK_GAP_SOURCE:              "gap_source",
+	BLOCK_OLD:                     "old",
+	BLOCK_BAD_SIGNATURE:           "bad_signature",
+	BLOCK_NEGATIVE_SPEND:          "negative_spend",
+	BLOCK_UNRECEIVABLE:            "unreceivable",
+	BLOCK_BALANCE_MISMATCH:        "balance_mismatch",
+	BLOCK_REPRESENTATIVE_MISMATCH: "representative_mismatch",
+	BLOCK_POSITION:                "position",
+	BLOCK_INVALID:                 "invalid",
+}
+
+func (status BlockStatus) String() string {
+	if status >= BLOCK_STATUS_COUNT {
+		return "unknown"
+	}
+
+	return blockStatusNames[status]
+}
+
+// What accepting an unchecked block on top of its unconfirmed ancestors results in.
+// Blocks building on it are checked against this instead of the ledger, which only has confirmed blocks.
+type pendingState struct {
+	Account    ledger.AccountInfo // Account once the block is applied
+	Receivable *ledger.Receivable // Set for sends
+}
+
+// ledger.View of a block's account as of its previous block, which may still be unconfirmed
+type pendingView struct {
+	manager *UncheckedBlocksManager
+	account *types.Address
+	base    *ledger.AccountInfo // nil for open blocks
+}
+
+func (view *pendingView) GetAccountInfo(address *types.Address) (*ledger.AccountInfo, error) {
+	if view.account != nil && *address == *view.account {
+		return view.base, nil
+	}
+
+	return ledgerAccountInfo(view.manager.P2PServer.Database.Backend.GetAccount(address)), nil
+}
+
+func (view *pendingView) GetReceivable(key ledger.ReceivableKey) (*ledger.ReceivableInfo, error) {
+	info := view.manager.P2PServer.Database.Backend.GetReceivable(&key.Destination, &key.SendHash)
+	if info != nil {
+		return info, nil
+	}
+
+	state := view.manager.stateOf(key.SendHash)
+	if state != nil && state.Receivable != nil && state.Receivable.Key == key {
+		return &state.Receivable.Info, nil
+	}
+
+	return nil, nil
+}
+
+func (view *pendingView) GetBlockSideband(hash *types.Hash) (*types.Sideband, error) {
+	if state := view.manager.stateOf(*hash); state != nil {
+		return state.Account.Sideband, nil
+	}
+
+	return view.manager.P2PServer.Database.Backend.GetBlockSideband(hash), nil
+}
+
+func (view *pendingView) GetBlock(hash *types.Hash) (*types.Block, error) {
+	if block, found := view.manager.UncheckedBlocks[*hash]; found {
+		return block, nil
+	}
+
+	return view.manager.P2PServer.Database.Backend.GetBlock(hash), nil
+}
+
+func ledgerAccountInfo(account *types.Account) *ledger.AccountInfo {
+	if account == nil {
+		return nil
+	}
+
+	sideband := account.Sideband
+
+	return &ledger.AccountInfo{
+		Frontier:       account.Frontier.Hash,
+		Representative: account.Frontier.Representative,
+		Sideband:       &sideband,
+	}
+}
+
+// Callers hold UncheckedBlocksMutex
+func (manager *UncheckedBlocksManager) stateOf(hash types.Hash) *pendingState {
+	entry, found := manager.Entries[hash]
+	if !found {
+		return nil
+	}
+
+	return entry.State
+}
+
+// Whether another accepted unchecked block has the same root. Callers hold UncheckedBlocksMutex.
+func (manager *UncheckedBlocksManager) hasAcceptedSibling(block *types.Block) bool {
+	if block.IsOpenBlock() {
+		blocks := manager.AccountBlocks[*block.Account]
+		if blocks == nil {
+			return false
+		}
+
+		for element := blocks.Front(); element != nil; element = element.Next() {
+			hash := element.Value.(types.Hash)
+			if hash != *block.Hash && manager.stateOf(hash) != nil && manager.UncheckedBlocks[hash].IsOpenBlock() {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	for sibling := range manager.Dependents[*block.Previous] {
+		if sibling != *block.Hash && manager.stateOf(sibling) != nil && manager.UncheckedBlocks[sibling].Previous.Cmp(block.Previous) == 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Finds the state of the account that block builds on, forks are only reported here when a confirmed block
+// has the same root. Callers hold UncheckedBlocksMutex.
+func (manager *UncheckedBlocksManager) baseOf(block *types.Block) (*ledger.AccountInfo, BlockStatus) {
+	backend := manager.P2PServer.Database.Backend
+
+	if block.IsOpenBlock() {
+		if backend.GetAccount(block.Account) != nil {
+			// The account was opened by a confirmed block already
+			return nil, BLOCK_FORK
+		}
+
+		return nil, BLOCK_PROGRESS
+	}
+
+	if _, found := manager.UncheckedBlocks[*block.Previous]; found {
+		state := manager.stateOf(*block.Previous)
+		if state == nil {
+			// The previous block is itself waiting for something
+			return nil, BLOCK_GAP_PREVIOUS
+		}
+
+		account := state.Account
+
+		return &account, BLOCK_PROGRESS
+	}
+
+	previous := backend.GetBlock(block.Previous)
+	if previous == nil {
+		if backend.IsBlockPruned(block.Previous) {
+			// Pruned blocks are below their account's frontier, something else follows them
+			return nil, BLOCK_FORK
+		}
+
+		return nil, BLOCK_GAP_PREVIOUS
+	}
+
+	account := ledgerAccountInfo(backend.GetAccount(previous.Account))
+	if account == nil || account.Frontier.Cmp(block.Previous) != 0 {
+		// A confirmed block already follows the previous block
+		return nil, BLOCK_FORK
+	}
+
+	return account, BLOCK_PROGRESS
+}
+
+func statusOfLedgerError(err error) BlockStatus {
+	switch {
+	case errors.Is(err, ledger.ErrNegativeSpend):
+		return BLOCK_NEGATIVE_SPEND
+	case errors.Is(err, ledger.ErrBalanceMismatch):
+		return BLOCK_BALANCE_MISMATCH
+	case errors.Is(err, ledger.ErrRepresentativeMismatch):
+		return BLOCK_REPRESENTATIVE_MISMATCH
+	case errors.Is(err, ledger.ErrBlockPosition):
+		return BLOCK_POSITION
+	case errors.Is(err, ledger.ErrUnreceivable):
+		return BLOCK_UNRECEIVABLE
+	case errors.Is(err, ledger.ErrPreviousNotFound), errors.Is(err, ledger.ErrOpenBlockExpected):
+		return BLOCK_GAP_PREVIOUS
+	case errors.Is(err, ledger.ErrPreviousNotFrontier):
+		return BLOCK_FORK
+	}
+
+	return BLOCK_INVALID
+}
+
+// A receive of a send we don't know yet is a gap, not an invalid block. Callers hold UncheckedBlocksMutex.
+func (manager *UncheckedBlocksManager) isSourceKnown(source types.Hash) bool {
+	if manager.stateOf(source) != nil {
+		return true
+	}
+
+	return manager.UncheckedBlocks[source] == nil && manager.P2PServer.Database.HasBlock(&source)
+}
+
+// Checks block against the ledger and the unconfirmed blocks it builds on. The account of legacy blocks is filled in
+// once their previous block is known. Callers hold UncheckedBlocksMutex.
+func (manager *UncheckedBlocksManager) validate(block *types.Block) (BlockStatus, *pendingState) {
+	if block.Account == nil && block.IsOpenBlock() {
+		return BLOCK_INVALID, nil
+	}
+
+	base, status := manager.baseOf(block)
+	if status != BLOCK_PROGRESS {
+		return status, nil
+	}
+
+	// Legacy blocks only carry their account through their previous block
+	unsigned := block.Account == nil
+	if unsigned {
+		block.Account = base.Sideband.Account
+	}
+
+	if unsigned && !manager.ValidateSignature(block) {
+		return BLOCK_BAD_SIGNATURE, nil
+	}
+
+	changes, err := ledger.ProcessBlock(&pendingView{manager: manager, account: block.Account, base: base}, block)
+	if err != nil {
+		status := statusOfLedgerError(err)
+		if status == BLOCK_UNRECEIVABLE && !manager.isSourceKnown(types.Hash(*block.Link)) {
+			status = BLOCK_GAP_SOURCE
+		}
+
+		return status, nil
+	}
+
+	state := &pendingState{Account: *changes.Account, Receivable: changes.AddReceivable}
+	if manager.hasAcceptedSibling(block) {
+		// Valid, but competing with an unconfirmed block. Both are kept and votes decide between them.
+		return BLOCK_FORK, state
+	}
+
+	return BLOCK_PROGRESS, state
+}
+
+// Whether a block with this outcome stays in the unchecked table. Forks only do when they compete with unconfirmed blocks.
+func isKept(status BlockStatus, state *pendingState) bool {
+	switch status {
+	case BLOCK_PROGRESS, BLOCK_GAP_PREVIOUS, BLOCK_GAP_SOURCE:
+		return true
+	case BLOCK_FORK:
+		return state != nil
+	}
+
+	return false
+}
+
+// Validates a held block again, it's dropped if it turned out invalid. Returns whether it was accepted.
+// Callers hold UncheckedBlocksMutex.
+func (manager *UncheckedBlocksManager) settle(hash types.Hash) bool {
+	status, state := manager.validate(manager.UncheckedBlocks[hash])
+	if !isKept(status, state) {
+		manager.remove(hash)
+		manager.ProcessedCounts[status]++
+
+		return false
+	}
+
+	manager.Entries[hash].State = state
+
+	return state != nil
+}
+
+// Re-checks the blocks that were held waiting for hash, and the blocks waiting for those. Callers hold UncheckedBlocksMutex.
+func (manager *UncheckedBlocksManager) releaseHeld(hash types.Hash) {
+	queue := []types.Hash{hash}
+	for len(queue) > 0 {
+		dependency := queue[0]
+		queue = queue[1:]
+
+		for dependent := range manager.Dependents[dependency] {
+			if manager.stateOf(dependent) == nil && manager.settle(dependent) {
+				queue = append(queue, dependent)
+			}
+		}
+	}
+}
+
+// Releases the blocks held for a block that was stored in the ledger
+func (manager *UncheckedBlocksManager) ReleaseHeld(hash *types.Hash) {
+	manager.UncheckedBlocksMutex.Lock()
+	manager.releaseHeld(*hash)
+	manager.UncheckedBlocksMutex.Unlock()
+}
+
+// Checks an incoming block and keeps it in the unchecked table if it's valid or waiting for a block we don't have.
+// Forks of confirmed blocks are dropped, forks of unconfirmed ones are kept for their election.
+func (manager *UncheckedBlocksManager) ProcessBlock(block *types.Block, source string) BlockStatus {
+	// Legacy blocks without an account are verified once their previous block is known
+	if block.Account != nil && !manager.ValidateSignature(block) {
+		manager.UncheckedBlocksMutex.Lock()
+		manager.ProcessedCounts[BLOCK_BAD_SIGNATURE]++
+		manager.UncheckedBlocksMutex.Unlock()
+
+		log.Println("Encountered block with invalid signature:", block.Hash.ToHexString())
+
+		return BLOCK_BAD_SIGNATURE
+	}
+
+	manager.UncheckedBlocksMutex.Lock()
+	status := manager.process(block, source)
+	manager.ProcessedCounts[status]++
+	manager.UncheckedBlocksMutex.Unlock()
+
+	manager.addCandidates()
+
+	switch status {
+	case BLOCK_PROGRESS, BLOCK_FORK, BLOCK_GAP_PREVIOUS, BLOCK_GAP_SOURCE:
+		// Confirmed blocks that were waiting for this one can be stored now
+		manager.P2PServer.Workers.ConfirmAck.ReleaseDependents(block.Hash)
+	case BLOCK_OLD:
+	default:
+		log.Println("Rejected block", block.Hash.ToHexString(), "from", source, ":", status.String())
+	}
+
+	return status
+}
+
+// Callers hold UncheckedBlocksMutex
+func (manager *UncheckedBlocksManager) process(block *types.Block, source string) BlockStatus {
+	if _, found := manager.Entries[*block.Hash]; found || manager.P2PServer.Database.HasBlock(block.Hash) {
+		return BLOCK_OLD
+	}
+
+	status, state := manager.validate(block)
+	if !isKept(status, state) {
+		return status
+	}
+
+	now := time.Now().UnixMilli()
+	manager.insert(block, now, source)
+	manager.Entries[*block.Hash].State = state
+	manager.schedulePersist(*block.Hash, &ledger.UncheckedInfo{Block: *block, Received: now})
+
+	if state != nil {
+		manager.releaseHeld(*block.Hash)
+	}
+
+	manager.enforceLimits(now)
+
+	return status
+}
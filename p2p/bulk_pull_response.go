@@ -27,14 +27,11 @@ func (srv *P2P) HandleBulkPullResponse(reader packets.PacketReader, peer *networ
 
 		// log.Println("Received block with hash:", block.Hash.ToHexString())
 
-		ledgerBlock := srv.Database.Backend.GetBlock(block.Hash)
-		if ledgerBlock == nil {
-			// Block is unknown, add to unchecked table and request votes from live peers
-			srv.UncheckedBlocksManager.Add(block)
+		// Pruned blocks are part of the ledger too, they don't have to be confirmed again
+		if !srv.Database.HasBlock(block.Hash) {
+			// Block is unknown, add to unchecked table. Votes are requested once its election is scheduled.
+			srv.UncheckedBlocksManager.Add(block, peer)
 			srv.BootstrapDataManager.FoundBlockBody(*block.Hash)
-			srv.Workers.ConfirmReq.RequestVotesOnTheseBlocks([][]byte{
-				append(block.Hash[:], block.Root()[:]...),
-			}, peer)
 		}
 
 		count++
@@ -15,7 +15,17 @@ type P2PConfig struct {
 }
 
 type ConsensusConfig struct {
-	TrustedPRs map[string]bool
+	TrustedPRs          map[string]bool // Final votes from these representatives cement blocks right away, without waiting for quorum
+	QuorumPercent       uint            // of the online voting weight that has to final vote a block to cement it, 0 only lets TrustedPRs cement blocks
+	OnlineWeightMinimum string          // in raw, the online voting weight is never considered lower than this, empty uses DEFAULT_ONLINE_WEIGHT_MINIMUM
+	ActiveElectionsSize uint            // Elections requesting votes at the same time, 0 uses DEFAULT_ACTIVE_ELECTIONS
+	VoteCacheSize       uint            // Blocks whose votes are kept until the block arrives, 0 uses DEFAULT_VOTE_CACHE_SIZE
+}
+
+type TxPoolConfig struct {
+	MaxUncheckedCount uint // 0 lets the unchecked table grow without bound
+	UncheckedLifetime uint // in ms, 0 keeps unchecked blocks until they're confirmed
+	PersistUnchecked  bool // Store the unchecked table in the database so that it survives restarts
 }
 
 type Config struct {
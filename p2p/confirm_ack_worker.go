@@ -2,11 +2,13 @@ package p2p
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"sync"
 	"time"
 
+	"github.com/Shryder/gnano/database/ledger"
 	"github.com/Shryder/gnano/p2p/networking"
 	"github.com/Shryder/gnano/p2p/packets"
 	"github.com/Shryder/gnano/types"
@@ -23,13 +25,13 @@ type ConfirmAckWorker struct {
 
 	CementQueue chan *types.Hash // Queue of block hashes to cement
 
-	ConfirmedButWaitingForBlockBody      map[types.Hash]bool // We tried to cement but we did not have block body, continuously check if we received the body yet
-	ConfirmedButWaitingForBlockBodyMutex sync.RWMutex
+	// Confirmed blocks that couldn't be stored yet, keyed by the block they're waiting for: their own body,
+	// a block missing below them or the send they receive. They're retried as soon as that block arrives or is cemented.
+	ConfirmedButWaiting      map[types.Hash]map[types.Hash]bool // mapping(dependency => confirmed hashes)
+	ConfirmedButWaitingMutex sync.Mutex
 
 	ConfirmAckQueue      map[*networking.PeerNode]chan *packets.ConfirmAckByHashes
 	ConfirmAckQueueMutex sync.RWMutex
-
-	BlockVotes map[types.Hash]map[types.Address]types.Amount // mapping(block_hash => mapping(voter => weight))
 }
 
 func (worker *ConfirmAckWorker) IsTrustedPR(address types.Address) bool {
@@ -38,7 +40,8 @@ func (worker *ConfirmAckWorker) IsTrustedPR(address types.Address) bool {
 	return found && is_trusted
 }
 
-// Traverses the account's blockchain until it reaches the cemented block.
+// Traverses the account's blockchain until it reaches a block stored in the ledger.
+// Returns the unchecked blocks above the ledger's frontier ordered from lowest height, `hash` being the last one.
 func (worker *ConfirmAckWorker) GetChainUntilCementedFrontier(hash types.Hash) ([]*types.Hash, *types.Hash) {
 	block := worker.P2PServer.UncheckedBlocksManager.Get(&hash)
 
@@ -53,14 +56,11 @@ func (worker *ConfirmAckWorker) GetChainUntilCementedFrontier(hash types.Hash) (
 		return []*types.Hash{&hash}, nil
 	}
 
-	chain := []*types.Hash{}
+	chain := []*types.Hash{&hash}
 	cursor := block.Previous
 	for {
-		ledgerBlock := worker.P2PServer.Database.Backend.GetBlock(cursor)
-		if ledgerBlock != nil {
-			// Because this block was found in the ledger, that means we reached the frontier cemented block in this account's chain
-			chain = append([]*types.Hash{cursor}, chain...)
-
+		if worker.P2PServer.Database.HasBlock(cursor) {
+			// Because this block was found in the ledger, that means we reached the frontier of this account's chain
 			return chain, nil
 		}
 
@@ -102,56 +102,31 @@ func (worker *ConfirmAckWorker) HandleConfirmAck(peer *networking.PeerNode, vote
 		return
 	}
 
-	if !worker.P2PServer.VotingEnabled && !vote.TimestampAndVoteDuration.IsFinalVote() {
-		log.Println("Received confirm_ack that we are ignoring because it's not a final vote:", vote.TimestampAndVoteDuration.IsFinalVote())
-		return
+	timestamp := vote.TimestampAndVoteDuration.Decode()
+	weight := worker.P2PServer.Database.GetVotingWeight(vote.Account)
+	if !weight.IsZero() {
+		worker.P2PServer.OnlineReps.Observe(*vote.Account, weight)
 	}
 
 	for _, hash := range *vote.Hashes {
 		log.Println("Received confirm_ack votes from", peer.NodeID.ToNodeAddress(), "on", hash.ToHexString(), "using account", vote.Account.ToNanoAddress())
 
-		ledgerBlock := worker.P2PServer.Database.Backend.GetBlock(hash)
-		if ledgerBlock != nil {
-			log.Println("Block already cemented:", ledgerBlock.Hash.ToHexString())
+		if worker.P2PServer.Database.IsBlockCemented(hash) {
+			log.Println("Block already cemented:", hash.ToHexString())
 			continue
 		}
 
-		// Instantly cement block if it was a final vote from a trusted PR
-		if worker.IsTrustedPR(*vote.Account) {
+		if !weight.IsZero() && worker.P2PServer.ElectionsManager.Vote(*vote.Account, weight, *hash, timestamp) {
+			log.Println("Final votes on", hash.ToHexString(), "reached quorum")
+
 			worker.TryCementBlock(*hash)
 			continue
 		}
 
-		// Add vote to list of votes that we already received for this block
-		// _, found := worker.BlockVotes[*hash]
-		// if !found {
-		// 	// First vote on a block hash
-		// 	worker.BlockVotes[*hash] = make(map[types.Address]types.Amount)
-		// }
-
-		// voting_weight := worker.P2PServer.Database.Backend.GetVotingWeight(vote.Account)
-		// if voting_weight.IsZero() {
-		// 	continue
-		// }
-
-		// _, found = worker.BlockVotes[*hash][*vote.Account]
-		// if found {
-		// 	continue
-		// }
-
-		// // Add this vote's weight
-		// worker.BlockVotes[*hash][*vote.Account] = voting_weight
-
-		// // Can be cached instead of re-calculated everytime
-		// total_voting_weight := types.Amount{}
-		// for _, i := range worker.BlockVotes[*hash] {
-		// 	total_voting_weight = i.Add(total_voting_weight)
-		// }
-
-		// minimum_weight_to_cement, _ := types.AmountFromString("42000000000000000000000000000000000000") // 42,000,000 XNO
-		// if total_voting_weight.Cmp(minimum_weight_to_cement) == 1 {
-		// 	worker.TryCementBlock(*hash)
-		// }
+		// Instantly cement block if it was a final vote from a trusted PR
+		if timestamp.Final && worker.IsTrustedPR(*vote.Account) {
+			worker.TryCementBlock(*hash)
+		}
 	}
 }
 
@@ -159,13 +134,22 @@ func (worker *ConfirmAckWorker) StartCementProcessor() {
 	for {
 		hashToCement := <-worker.CementQueue
 
+		if worker.P2PServer.Database.IsBlockCemented(hashToCement) {
+			// Cemented while it was queued, or pruned since
+			continue
+		}
+
+		if worker.P2PServer.Database.Backend.GetBlock(hashToCement) != nil {
+			// Already stored, only the confirmation height has to move
+			worker.CementStoredBlock(hashToCement)
+			continue
+		}
+
 		unchecked_block := worker.P2PServer.UncheckedBlocksManager.Get(hashToCement)
 		if unchecked_block == nil {
 			log.Println("Couldn't cement block", hashToCement.ToHexString(), "because we don't have its body")
 
-			worker.ConfirmedButWaitingForBlockBodyMutex.Lock()
-			worker.ConfirmedButWaitingForBlockBody[*hashToCement] = true
-			worker.ConfirmedButWaitingForBlockBodyMutex.Unlock()
+			worker.WaitForDependency(*hashToCement, *hashToCement, worker.isKnown)
 
 			// Notify bootstrapper to request the missing block from peers
 			worker.P2PServer.BootstrapDataManager.AddUnknownBlockHash(hashToCement)
@@ -179,40 +163,126 @@ func (worker *ConfirmAckWorker) StartCementProcessor() {
 		if gap != nil {
 			log.Println("Found gap in chain, unable to cement for now. Chain length:", len(chain), "account:", unchecked_block.Account.ToNanoAddress(), "gap at:", gap.ToHexString())
 
+			worker.WaitForDependency(*gap, *hashToCement, worker.isKnown)
+
 			// Notify bootstrapper to request the missing gap block from peers
 			worker.P2PServer.BootstrapDataManager.AddUnknownBlockHash(gap)
 
 			continue
 		}
 
-		// Loop the chain in reverse to start cementing from lowest height
-		for _, hash := range chain {
-			log.Println("Cementing block", hash.ToHexString())
+		// Chain is ordered from lowest height, blocks exist otherwise GetChainUntilCementedFrontier wouldn't return them
+		blocks := make([]*types.Block, len(chain))
+		for i, hash := range chain {
+			blocks[i] = worker.P2PServer.UncheckedBlocksManager.Get(hash)
+		}
 
-			// Block exists otherwise GetChainUntilCementedFrontier wouldn't return it
-			block := worker.P2PServer.UncheckedBlocksManager.Get(hash)
+		// Segments stored before a rejected one stay in the ledger, the retry continues above them
+		if !worker.storeChain(hashToCement, unchecked_block, chain, blocks) {
+			continue
+		}
 
-			// Save to ledger
-			err := worker.P2PServer.Database.Backend.PutBlock(block)
-			if err != nil {
-				chain_jsonified, _ := json.Marshal(chain)
-				log.Println("Error saving block to ledger:", err, "chain:", string(chain_jsonified), "account cemented chain:", worker.P2PServer.Database.Backend.GetAccountChain(block.Account))
+		worker.CementStoredBlock(hashToCement)
+	}
+}
+
+// Saves the chain to the ledger in segments of at most ledger.MAX_PUT_BLOCKS, each of which is stored entirely or not at
+// all. Returns whether the whole chain was stored.
+func (worker *ConfirmAckWorker) storeChain(hashToCement *types.Hash, unchecked_block *types.Block, chain []*types.Hash, blocks []*types.Block) bool {
+	for start := 0; start < len(blocks); start += ledger.MAX_PUT_BLOCKS {
+		end := start + ledger.MAX_PUT_BLOCKS
+		if end > len(blocks) {
+			end = len(blocks)
+		}
 
-				panic("Error saving block to ledger")
+		segment := blocks[start:end]
+
+		err := worker.P2PServer.Database.Backend.PutBlocks(segment)
+		if err != nil {
+			var blockError *ledger.BlockError
+			if errors.As(err, &blockError) && errors.Is(err, ledger.ErrUnreceivable) {
+				// The send being received isn't cemented yet, retry once it is and make sure we are pulling it
+				log.Println("Postponing cementing of", hashToCement.ToHexString(), "because block", blockError.Hash.ToHexString(), "receives a send that is not cemented yet")
+
+				source := types.Hash(*segment[blockError.Index].Link)
+				if worker.P2PServer.UncheckedBlocksManager.Get(&source) == nil {
+					worker.P2PServer.BootstrapDataManager.AddUnknownBlockHash(&source)
+				}
+
+				worker.WaitForDependency(source, *hashToCement, worker.P2PServer.Database.IsBlockCemented)
+			} else if errors.As(err, &blockError) {
+				chain_jsonified, _ := json.Marshal(chain)
+				log.Println("Ledger rejected block", blockError.Hash.ToHexString(), "while cementing chain:", string(chain_jsonified), "account cemented chain:", worker.P2PServer.Database.Backend.GetAccountChain(unchecked_block.Account), "error:", blockError.Err)
+			} else {
+				log.Println("Error saving", len(segment), "blocks to ledger:", err)
 			}
 
-			// Don't request votes on this block anymore
-			worker.P2PServer.Workers.ConfirmReq.MarkBlockAsConfirmed(types.HashPair{Root: *block.Previous, Hash: *block.Hash})
+			return false
+		}
+
+		for _, block := range segment {
+			// Ends its election, blocks competing with this one can't be cemented anymore
+			losers := worker.P2PServer.ElectionsManager.Confirmed(block)
 
-			// Remove from unchecked table
-			worker.P2PServer.UncheckedBlocksManager.Remove(hash)
+			// Stored, the unchecked table doesn't have to hold it anymore
+			worker.P2PServer.UncheckedBlocksManager.Remove(block.Hash)
+			worker.P2PServer.UncheckedBlocksManager.ReleaseHeld(block.Hash)
 
-			// Don't request this block's body anymore
-			worker.ConfirmedButWaitingForBlockBodyMutex.Lock()
-			delete(worker.ConfirmedButWaitingForBlockBody, *hash)
-			worker.ConfirmedButWaitingForBlockBodyMutex.Unlock()
+			for _, loser := range losers {
+				worker.P2PServer.UncheckedBlocksManager.DropFork(loser)
+			}
 		}
 	}
+
+	return true
+}
+
+// Advances the confirmation heights up to hashToCement, which is already in the ledger
+func (worker *ConfirmAckWorker) CementStoredBlock(hashToCement *types.Hash) {
+	cemented, err := worker.P2PServer.Database.Backend.CementBlock(hashToCement)
+	if err != nil {
+		log.Println("Error cementing", hashToCement.ToHexString(), ":", err)
+
+		return
+	}
+
+	for _, hash := range cemented {
+		log.Println("Cemented block", hash.ToHexString())
+
+		// Receives of this block were waiting for it to be cemented
+		worker.ReleaseDependents(hash)
+	}
+}
+
+func (worker *ConfirmAckWorker) isKnown(hash *types.Hash) bool {
+	return worker.P2PServer.UncheckedBlocksManager.Get(hash) != nil || worker.P2PServer.Database.HasBlock(hash)
+}
+
+// Parks a confirmed block until dependency arrives or is cemented. ready tells whether the dependency is already
+// there, it may have shown up while the cement processor was looking for it and its release would have been missed.
+func (worker *ConfirmAckWorker) WaitForDependency(dependency types.Hash, hash types.Hash, ready func(hash *types.Hash) bool) {
+	worker.ConfirmedButWaitingMutex.Lock()
+	if worker.ConfirmedButWaiting[dependency] == nil {
+		worker.ConfirmedButWaiting[dependency] = make(map[types.Hash]bool)
+	}
+	worker.ConfirmedButWaiting[dependency][hash] = true
+	worker.ConfirmedButWaitingMutex.Unlock()
+
+	if ready(&dependency) {
+		worker.ReleaseDependents(&dependency)
+	}
+}
+
+// Retries cementing the confirmed blocks that were waiting for the block with this hash
+func (worker *ConfirmAckWorker) ReleaseDependents(hash *types.Hash) {
+	worker.ConfirmedButWaitingMutex.Lock()
+	waiting := worker.ConfirmedButWaiting[*hash]
+	delete(worker.ConfirmedButWaiting, *hash)
+	worker.ConfirmedButWaitingMutex.Unlock()
+
+	for confirmed := range waiting {
+		worker.TryCementBlock(confirmed)
+	}
 }
 
 func (worker *ConfirmAckWorker) StartQueueProcessor() {
@@ -236,25 +306,11 @@ func (worker *ConfirmAckWorker) StartQueueProcessor() {
 	}
 }
 
-// Continuously retry cementing the blocks that we couldn't cement before because we didn't have block body
-func (worker *ConfirmAckWorker) WaitForBlockBodyBeforeCementing() {
-	for {
-		worker.ConfirmedButWaitingForBlockBodyMutex.RLock()
-		for hash := range worker.ConfirmedButWaitingForBlockBody {
-			worker.TryCementBlock(hash)
-		}
-		worker.ConfirmedButWaitingForBlockBodyMutex.RUnlock()
-
-		time.Sleep(time.Millisecond * 1000)
-	}
-}
-
 func (worker *ConfirmAckWorker) Start() {
 	for i := 0; i < 16; i++ {
 		go worker.StartQueueProcessor()
 	}
 
-	go worker.WaitForBlockBodyBeforeCementing()
 	go worker.StartCementProcessor()
 }
 
@@ -278,13 +334,11 @@ func NewConfirmAckWorker(srv *P2P) *ConfirmAckWorker {
 	return &ConfirmAckWorker{
 		P2PServer: srv,
 
-		CementQueue:                          make(chan *types.Hash, 512_000),
-		ConfirmedButWaitingForBlockBody:      make(map[types.Hash]bool),
-		ConfirmedButWaitingForBlockBodyMutex: sync.RWMutex{},
+		CementQueue:              make(chan *types.Hash, 512_000),
+		ConfirmedButWaiting:      make(map[types.Hash]map[types.Hash]bool),
+		ConfirmedButWaitingMutex: sync.Mutex{},
 
 		ConfirmAckQueue:      make(map[*networking.PeerNode]chan *packets.ConfirmAckByHashes),
 		ConfirmAckQueueMutex: sync.RWMutex{},
-
-		BlockVotes: make(map[types.Hash]map[types.Address]types.Amount),
 	}
 }
@@ -0,0 +1,216 @@
+package p2p
+
+import (
+	"container/heap"
+	"math/bits"
+	"time"
+
+	"github.com/Shryder/gnano/types"
+)
+
+const (
+	ELECTION_BUCKETS          = 17            // Accounts are bucketed by the bit length of their balance, 8 bits per bucket
+	ELECTION_LIFETIME         = 5 * 60 * 1000 // in ms, active elections that aren't confirmed by then expire
+	ELECTION_REQUEST_INTERVAL = 1000          // in ms, votes for the same election are requested at most this often
+	DEFAULT_ACTIVE_ELECTIONS  = 5000          // Used when ActiveElectionsSize isn't set
+	CONFIRM_REQ_MAX_PAIRS     = 12
+)
+
+type scheduledElection struct {
+	Root         types.Hash
+	LastActivity int64
+}
+
+// Elections of a bucket waiting to be activated, the account that was inactive for the longest first.
+// Elections that ended or were activated since they were queued are skipped when they reach the top.
+type electionQueue []scheduledElection
+
+func (queue electionQueue) Len() int {
+	return len(queue)
+}
+
+func (queue electionQueue) Less(i, j int) bool {
+	if queue[i].LastActivity != queue[j].LastActivity {
+		return queue[i].LastActivity < queue[j].LastActivity
+	}
+
+	return queue[i].Root.Cmp(&queue[j].Root) < 0
+}
+
+func (queue electionQueue) Swap(i, j int) {
+	queue[i], queue[j] = queue[j], queue[i]
+}
+
+func (queue *electionQueue) Push(item interface{}) {
+	*queue = append(*queue, item.(scheduledElection))
+}
+
+func (queue *electionQueue) Pop() interface{} {
+	old := *queue
+	item := old[len(old)-1]
+	*queue = old[:len(old)-1]
+
+	return item
+}
+
+// Spam is usually sent from accounts with tiny balances, bucketing by balance keeps it from crowding out everything else
+func balanceBucket(balance types.Amount) int {
+	length := bits.Len64(balance.Lo)
+	if balance.Hi != 0 {
+		length = 64 + bits.Len64(balance.Hi)
+	}
+
+	return length / 8
+}
+
+// Bucket and last activity of the account block belongs to. Sends are prioritised by the balance they're sent from.
+func (manager *ElectionsManager) priorityOf(block *types.Block) (int, int64) {
+	balance := types.Amount{}
+	if block.Balance != nil {
+		balance = *block.Balance
+	}
+
+	last_activity := int64(0)
+	if block.Account != nil {
+		account := manager.P2PServer.Database.Backend.GetAccount(block.Account)
+		if account != nil {
+			if account.Sideband.Balance.Cmp(balance) > 0 {
+				balance = account.Sideband.Balance
+			}
+
+			last_activity = int64(account.Sideband.Timestamp)
+		}
+	}
+
+	return balanceBucket(balance), last_activity
+}
+
+func (manager *ElectionsManager) activeLimit() int {
+	limit := manager.P2PServer.Config.Consensus.ActiveElectionsSize
+	if limit == 0 {
+		return DEFAULT_ACTIVE_ELECTIONS
+	}
+
+	return int(limit)
+}
+
+// Callers hold Mutex
+func (manager *ElectionsManager) enqueue(election *Election) {
+	heap.Push(manager.Queues[election.Bucket], scheduledElection{Root: election.Root, LastActivity: election.LastActivity})
+}
+
+// Next election of the bucket that's waiting to be activated, nil if there's none. Callers hold Mutex.
+func (manager *ElectionsManager) dequeue(bucket int) *Election {
+	queue := manager.Queues[bucket]
+	for queue.Len() > 0 {
+		scheduled := heap.Pop(queue).(scheduledElection)
+
+		election, found := manager.Elections[scheduled.Root]
+		if found && (election.State == ELECTION_PASSIVE || election.State == ELECTION_EXPIRED) {
+			return election
+		}
+	}
+
+	return nil
+}
+
+// Activates queued elections until the active ones reach the limit, buckets take turns. Callers hold Mutex.
+func (manager *ElectionsManager) activate(now int64) {
+	limit := manager.activeLimit()
+
+	for empty := 0; manager.Active.Len() < limit && empty < ELECTION_BUCKETS; {
+		election := manager.dequeue(manager.nextBucket)
+		manager.nextBucket = (manager.nextBucket + 1) % ELECTION_BUCKETS
+
+		if election == nil {
+			empty++
+			continue
+		}
+
+		empty = 0
+
+		election.State = ELECTION_ACTIVE
+		election.Activated = now
+		election.LastRequest = 0
+
+		// Never requested votes, so it goes first
+		election.activeEntry = manager.Active.PushFront(election.Root)
+	}
+}
+
+// Callers hold Mutex
+func (manager *ElectionsManager) deactivate(election *Election) {
+	if election.activeEntry != nil {
+		manager.Active.Remove(election.activeEntry)
+		election.activeEntry = nil
+	}
+}
+
+// Gives the slot of an election that ran out of time to another one, it's queued again as if its account
+// had just been active. Callers hold Mutex.
+func (manager *ElectionsManager) expire(election *Election, now int64) {
+	manager.deactivate(election)
+
+	election.State = ELECTION_EXPIRED
+	election.LastActivity = now / 1000
+	manager.enqueue(election)
+
+	manager.Stats.Expirations++
+}
+
+// Hash pairs of the active elections that requested votes the longest ago, elections that ran out of time
+// are expired on the way. Callers hold Mutex.
+func (manager *ElectionsManager) nextVoteRequests(now int64) []types.HashPair {
+	pairs := make([]types.HashPair, 0, CONFIRM_REQ_MAX_PAIRS)
+
+	for element := manager.Active.Front(); element != nil && len(pairs) < CONFIRM_REQ_MAX_PAIRS; element = manager.Active.Front() {
+		election := manager.Elections[element.Value.(types.Hash)]
+		if election.Activated+ELECTION_LIFETIME < now {
+			manager.expire(election, now)
+			continue
+		}
+
+		if election.LastRequest+ELECTION_REQUEST_INTERVAL > now {
+			// The rest of the list requested votes even more recently
+			break
+		}
+
+		for candidate := range election.Candidates {
+			if len(pairs) == CONFIRM_REQ_MAX_PAIRS {
+				break
+			}
+
+			pairs = append(pairs, types.HashPair{Hash: candidate, Root: election.Root})
+		}
+
+		election.LastRequest = now
+		manager.Active.MoveToBack(element)
+	}
+
+	return pairs
+}
+
+// Activates elections as slots free up and requests votes for the active ones
+func (manager *ElectionsManager) RequestVotes() {
+	for {
+		now := time.Now().UnixMilli()
+
+		manager.Mutex.Lock()
+		pairs := manager.nextVoteRequests(now)
+		manager.activate(now)
+		manager.Mutex.Unlock()
+
+		if len(pairs) > 0 {
+			err := manager.P2PServer.SendConfirmReqToPeers(pairs)
+			if err != nil {
+				manager.P2PServer.Workers.ConfirmReq.Logger.Println("Error sending confirm_req to peer", err)
+			}
+		}
+
+		time.Sleep(time.Millisecond * 50)
+	}
+}
+
+func (manager *ElectionsManager) Start() {
+	go manager.RequestVotes()
+}
@@ -0,0 +1,361 @@
+package p2p
+
+import (
+	"container/list"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Shryder/gnano/p2p/packets"
+	"github.com/Shryder/gnano/types"
+)
+
+type ElectionState byte
+
+const (
+	ELECTION_PASSIVE   ElectionState = iota // Waiting for the scheduler to make room for it
+	ELECTION_ACTIVE                         // Requesting votes
+	ELECTION_CONFIRMED                      // A candidate's final votes reached quorum, waiting to be cemented
+	ELECTION_EXPIRED                        // Was active for ELECTION_LIFETIME without being confirmed, scheduled again behind its bucket
+)
+
+var electionStateNames = map[ElectionState]string{
+	ELECTION_PASSIVE:   "passive",
+	ELECTION_ACTIVE:    "active",
+	ELECTION_CONFIRMED: "confirmed",
+	ELECTION_EXPIRED:   "expired",
+}
+
+func (state ElectionState) String() string {
+	name, found := electionStateNames[state]
+	if !found {
+		return "unknown"
+	}
+
+	return name
+}
+
+func (state ElectionState) MarshalText() ([]byte, error) {
+	return []byte(state.String()), nil
+}
+
+// Blocks competing for the same root, every unchecked block is a candidate in the election of its root.
+// Only one of them can be cemented, the others are dropped along with the blocks building on them.
+type Election struct {
+	Root       types.Hash
+	Candidates map[types.Hash]bool
+	Votes      map[types.Address]ElectionVote // mapping(representative => its latest vote on the root)
+	Winner     *types.Hash                    // Candidate whose final votes reached quorum, set once
+	State      ElectionState
+	Started    int64 // Unix milliseconds
+
+	Bucket       int           // Balance bucket the election is scheduled in
+	LastActivity int64         // Unix seconds of the account's latest confirmed block, 0 for new accounts
+	Activated    int64         // Unix milliseconds of when the election last became active
+	LastRequest  int64         // Unix milliseconds of when votes were last requested
+	activeEntry  *list.Element // Position in ElectionsManager.Active while active
+}
+
+type ElectionVote struct {
+	Hash      types.Hash
+	Weight    types.Amount // Voting weight of the representative when its vote was counted
+	Timestamp uint64       // As signed by the representative, later votes replace earlier ones
+	Final     bool
+}
+
+type ElectionStats struct {
+	Passive     int    `json:"passive"`
+	Active      int    `json:"active"`
+	Confirmed   int    `json:"confirmed"`
+	Expired     int    `json:"expired"`
+	Forks       uint64 `json:"forks"`       // Elections that had more than one candidate
+	Cemented    uint64 `json:"cemented"`    // Elections ended by one of their candidates being cemented
+	Expirations uint64 `json:"expirations"` // Times an active election expired
+	VoteCache   int    `json:"vote_cache"`  // Blocks with votes waiting for them to arrive
+	Replayed    uint64 `json:"replayed"`    // Cached votes counted once their block arrived
+	Stale       uint64 `json:"stale"`       // Votes older than the representative's latest vote on the same root
+	Duplicates  uint64 `json:"duplicates"`  // Votes with the same timestamp as the representative's latest vote on the same root
+}
+
+type ElectionsManager struct {
+	P2PServer *P2P
+
+	Elections map[types.Hash]*Election  // mapping(root => election)
+	Roots     map[types.Hash]types.Hash // mapping(candidate => root)
+	Active    *list.List                // Roots of the active elections, the one that requested votes the longest ago at the front
+	Queues    [ELECTION_BUCKETS]*electionQueue
+	VoteCache *VoteCache
+	Latest    *LatestVotes
+	Stats     ElectionStats
+	Mutex     sync.RWMutex
+
+	nextBucket int // Buckets take turns activating elections
+}
+
+func NewElectionsManager(srv *P2P) ElectionsManager {
+	var queues [ELECTION_BUCKETS]*electionQueue
+	for bucket := range queues {
+		queues[bucket] = &electionQueue{}
+	}
+
+	return ElectionsManager{
+		P2PServer: srv,
+		Elections: make(map[types.Hash]*Election),
+		Roots:     make(map[types.Hash]types.Hash),
+		Active:    list.New(),
+		Queues:    queues,
+		VoteCache: NewVoteCache(srv.Config.Consensus.VoteCacheSize),
+		Latest:    NewLatestVotes(LATEST_VOTES_SIZE),
+		Mutex:     sync.RWMutex{},
+	}
+}
+
+func (election *Election) IsFork() bool {
+	return len(election.Candidates) > 1
+}
+
+// Voting weight behind each candidate, only counting final votes when final is set
+func (election *Election) Tally(final bool) map[types.Hash]types.Amount {
+	tally := make(map[types.Hash]types.Amount, len(election.Candidates))
+	for candidate := range election.Candidates {
+		tally[candidate] = types.Amount{}
+	}
+
+	for _, vote := range election.Votes {
+		// Votes for candidates that were dropped since don't count
+		if election.Candidates[vote.Hash] && (vote.Final || !final) {
+			tally[vote.Hash] = tally[vote.Hash].Add(vote.Weight)
+		}
+	}
+
+	return tally
+}
+
+// Adds block to the election of its root, starting it if needed, and counts the votes that arrived before it.
+// Returns whether the block forks another candidate.
+func (manager *ElectionsManager) AddCandidate(block *types.Block) bool {
+	root := *block.Root()
+	bucket, last_activity := manager.priorityOf(block)
+	quorum_delta, quorum_enabled := manager.P2PServer.OnlineReps.QuorumDelta()
+
+	manager.Mutex.Lock()
+
+	election, found := manager.Elections[root]
+	if !found {
+		election = &Election{
+			Root:         root,
+			Candidates:   make(map[types.Hash]bool),
+			Votes:        make(map[types.Address]ElectionVote),
+			State:        ELECTION_PASSIVE,
+			Started:      time.Now().UnixMilli(),
+			Bucket:       bucket,
+			LastActivity: last_activity,
+		}
+
+		manager.Elections[root] = election
+		manager.enqueue(election)
+	}
+
+	was_fork := election.IsFork()
+	election.Candidates[*block.Hash] = true
+	manager.Roots[*block.Hash] = root
+
+	if election.IsFork() && !was_fork {
+		manager.Stats.Forks++
+	}
+
+	confirmed := false
+	for representative, vote := range manager.VoteCache.Take(*block.Hash) {
+		confirmed = manager.count(election, representative, vote, quorum_delta, quorum_enabled) || confirmed
+		manager.Stats.Replayed++
+	}
+
+	is_fork := election.IsFork()
+	manager.Mutex.Unlock()
+
+	if confirmed {
+		log.Println("Final votes received before", block.Hash.ToHexString(), "reached quorum")
+
+		manager.P2PServer.Workers.ConfirmAck.TryCementBlock(*block.Hash)
+	}
+
+	return is_fork
+}
+
+// Drops a candidate that left the unchecked table, the election ends with its last candidate
+func (manager *ElectionsManager) RemoveCandidate(hash types.Hash) {
+	manager.Mutex.Lock()
+	defer manager.Mutex.Unlock()
+
+	root, found := manager.Roots[hash]
+	if !found {
+		return
+	}
+
+	delete(manager.Roots, hash)
+
+	election := manager.Elections[root]
+	delete(election.Candidates, hash)
+	if len(election.Candidates) == 0 {
+		manager.deactivate(election)
+		delete(manager.Elections, root)
+	}
+}
+
+// Counts a representative's vote for the block with this hash, replacing its previous vote in the same election.
+// Only votes signed later than the previous one replace it, final votes are signed the latest possible.
+// Votes for blocks that aren't candidates yet are cached until they are.
+// Returns true when this vote made the block's final votes reach quorum.
+func (manager *ElectionsManager) Vote(representative types.Address, weight types.Amount, hash types.Hash, timestamp packets.VoteTimestamp) bool {
+	quorum_delta, quorum_enabled := manager.P2PServer.OnlineReps.QuorumDelta()
+	vote := ElectionVote{Hash: hash, Weight: weight, Timestamp: timestamp.Timestamp, Final: timestamp.Final}
+
+	manager.Mutex.Lock()
+	defer manager.Mutex.Unlock()
+
+	root, found := manager.Roots[hash]
+	if !found {
+		// The block's election ended, its votes are only kept if it's elected again and they're newer than the last ones
+		if past_root, known := manager.Latest.RootOf(hash); known && !manager.isLatest(representative, past_root, nil, vote) {
+			return false
+		}
+
+		manager.VoteCache.Add(representative, vote)
+
+		return false
+	}
+
+	return manager.count(manager.Elections[root], representative, vote, quorum_delta, quorum_enabled)
+}
+
+// Whether vote was signed later than every vote counted from the representative on root, an older vote replayed by
+// anyone can't undo its latest one. election is nil when root isn't being elected. Callers hold Mutex.
+func (manager *ElectionsManager) isLatest(representative types.Address, root types.Hash, election *Election, vote ElectionVote) bool {
+	previous, found := manager.Latest.Get(representative, root)
+	if election != nil {
+		// The table may have forgotten the root while its election is still running
+		if counted, counted_found := election.Votes[representative]; counted_found && (!found || counted.Timestamp > previous) {
+			previous, found = counted.Timestamp, true
+		}
+	}
+
+	if !found || vote.Timestamp > previous {
+		return true
+	}
+
+	if vote.Timestamp == previous {
+		manager.Stats.Duplicates++
+	} else {
+		manager.Stats.Stale++
+	}
+
+	return false
+}
+
+// Callers hold Mutex
+func (manager *ElectionsManager) count(election *Election, representative types.Address, vote ElectionVote, quorum_delta types.Amount, quorum_enabled bool) bool {
+	if !manager.isLatest(representative, election.Root, election, vote) {
+		return false
+	}
+
+	election.Votes[representative] = vote
+	manager.Latest.Put(representative, election.Root, vote.Hash, vote.Timestamp)
+
+	if !quorum_enabled || !vote.Final || election.Winner != nil {
+		return false
+	}
+
+	hash := vote.Hash
+	final_weight := election.Tally(true)[hash]
+	if final_weight.IsZero() || final_weight.Cmp(quorum_delta) < 0 {
+		return false
+	}
+
+	// No more votes are needed, its slot goes to another election
+	election.Winner = &hash
+	election.State = ELECTION_CONFIRMED
+	manager.deactivate(election)
+
+	return true
+}
+
+// Ends the election of a block that was cemented. Returns the candidates that lost to it.
+func (manager *ElectionsManager) Confirmed(block *types.Block) []types.Hash {
+	root := *block.Root()
+
+	manager.Mutex.Lock()
+	defer manager.Mutex.Unlock()
+
+	election, found := manager.Elections[root]
+	if !found {
+		return nil
+	}
+
+	manager.deactivate(election)
+	delete(manager.Elections, root)
+	manager.Stats.Cemented++
+
+	losers := make([]types.Hash, 0, len(election.Candidates))
+	for candidate := range election.Candidates {
+		delete(manager.Roots, candidate)
+
+		if candidate != *block.Hash {
+			losers = append(losers, candidate)
+		}
+	}
+
+	if len(losers) > 0 {
+		log.Println("Fork on root", root.ToHexString(), "resolved in favor of", block.Hash.ToHexString(), "dropping", len(losers), "competing blocks")
+	}
+
+	return losers
+}
+
+// Copy of the election the block with this hash is a candidate in, nil if it isn't in any
+func (manager *ElectionsManager) GetElection(hash *types.Hash) *Election {
+	manager.Mutex.RLock()
+	defer manager.Mutex.RUnlock()
+
+	root, found := manager.Roots[*hash]
+	if !found {
+		return nil
+	}
+
+	election := manager.Elections[root]
+	copied := *election
+	copied.Candidates = make(map[types.Hash]bool, len(election.Candidates))
+	copied.Votes = make(map[types.Address]ElectionVote, len(election.Votes))
+	copied.activeEntry = nil
+
+	for candidate := range election.Candidates {
+		copied.Candidates[candidate] = true
+	}
+
+	for representative, vote := range election.Votes {
+		copied.Votes[representative] = vote
+	}
+
+	return &copied
+}
+
+func (manager *ElectionsManager) GetStats() ElectionStats {
+	manager.Mutex.RLock()
+	defer manager.Mutex.RUnlock()
+
+	stats := manager.Stats
+	stats.VoteCache = manager.VoteCache.Len()
+	for _, election := range manager.Elections {
+		switch election.State {
+		case ELECTION_PASSIVE:
+			stats.Passive++
+		case ELECTION_ACTIVE:
+			stats.Active++
+		case ELECTION_CONFIRMED:
+			stats.Confirmed++
+		case ELECTION_EXPIRED:
+			stats.Expired++
+		}
+	}
+
+	return stats
+}
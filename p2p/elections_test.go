@@ -0,0 +1,172 @@
+package p2p
+
+import (
+	"testing"
+
+	"github.com/Shryder/gnano/database"
+	memory_backend "github.com/Shryder/gnano/database/memory"
+	"github.com/Shryder/gnano/p2p/packets"
+	"github.com/Shryder/gnano/types"
+)
+
+var (
+	testRepresentative = types.Address{0xaa}
+	testWeight         = types.Amount{Lo: 100}
+	finalVote          = packets.VoteTimestamp{Timestamp: ^uint64(0), DurationBits: packets.VOTE_DURATION_BITS_MASK, Final: true}
+)
+
+// Elections over an empty memory ledger, the online weight is testWeight so a final vote of testRepresentative reaches quorum
+func newTestElections() *ElectionsManager {
+	srv := New(&Config{Consensus: ConsensusConfig{QuorumPercent: 67}}, &TxPoolConfig{}, nil)
+	srv.Database = database.Database{Backend: memory_backend.New(nil), Config: &database.Config{}}
+	srv.OnlineReps.Minimum = testWeight
+
+	return &srv.ElectionsManager
+}
+
+func testBlock(hash byte, account types.Address) *types.Block {
+	return &types.Block{Type: types.BLOCK_TYPE_STATE, Hash: &types.Hash{hash}, Previous: &types.Hash{}, Account: &account}
+}
+
+func voteAt(timestamp uint64) packets.VoteTimestamp {
+	return packets.VoteTimestamp{Timestamp: timestamp}
+}
+
+func TestVoteTimestamps(t *testing.T) {
+	manager := newTestElections()
+
+	// Two candidates for the same root
+	first, second := testBlock(0x01, types.Address{0x10}), testBlock(0x02, types.Address{0x10})
+	manager.AddCandidate(first)
+	manager.AddCandidate(second)
+
+	steps := []struct {
+		name      string
+		hash      types.Hash
+		timestamp packets.VoteTimestamp
+		counted   types.Hash // Candidate the representative's vote is for afterwards
+		stale     uint64
+		duplicate uint64
+	}{
+		{"first vote", *first.Hash, voteAt(1000), *first.Hash, 0, 0},
+		{"older vote for the other candidate", *second.Hash, voteAt(500), *first.Hash, 1, 0},
+		{"same timestamp for the other candidate", *second.Hash, voteAt(1000), *first.Hash, 1, 1},
+		{"newer vote for the other candidate", *second.Hash, voteAt(2000), *second.Hash, 1, 1},
+		{"replayed first vote", *first.Hash, voteAt(1000), *second.Hash, 2, 1},
+	}
+
+	for _, step := range steps {
+		if manager.Vote(testRepresentative, testWeight, step.hash, step.timestamp) {
+			t.Fatalf("%s: non-final vote confirmed a block", step.name)
+		}
+
+		election := manager.GetElection(first.Hash)
+		vote := election.Votes[testRepresentative]
+		if vote.Hash != step.counted {
+			t.Errorf("%s: vote is for %s, want %s", step.name, vote.Hash.ToHexString(), step.counted.ToHexString())
+		}
+
+		stats := manager.GetStats()
+		if stats.Stale != step.stale || stats.Duplicates != step.duplicate {
+			t.Errorf("%s: %d stale and %d duplicate votes, want %d and %d", step.name, stats.Stale, stats.Duplicates, step.stale, step.duplicate)
+		}
+	}
+
+	tally := manager.GetElection(first.Hash).Tally(false)
+	if !tally[*first.Hash].IsZero() || tally[*second.Hash] != testWeight {
+		t.Errorf("tally is %v, want all of the weight on the second candidate", tally)
+	}
+
+	// Final votes replace every earlier vote and confirm the block once they reach quorum
+	if !manager.Vote(testRepresentative, testWeight, *first.Hash, finalVote) {
+		t.Fatal("final vote with all of the online weight didn't confirm the block")
+	}
+
+	election := manager.GetElection(first.Hash)
+	if election.State != ELECTION_CONFIRMED || election.Winner == nil || *election.Winner != *first.Hash {
+		t.Errorf("election is %s with winner %v, want confirmed for the first candidate", election.State, election.Winner)
+	}
+
+	// Nothing is signed later than a final vote
+	if manager.Vote(testRepresentative, testWeight, *second.Hash, finalVote) {
+		t.Error("second final vote confirmed a block again")
+	}
+
+	if manager.GetStats().Duplicates != 2 {
+		t.Error("second final vote wasn't counted as a duplicate")
+	}
+}
+
+func TestFinalVoteNeedsQuorum(t *testing.T) {
+	manager := newTestElections()
+
+	block := testBlock(0x01, types.Address{0x10})
+	manager.AddCandidate(block)
+
+	if manager.Vote(testRepresentative, types.Amount{Lo: 66}, *block.Hash, finalVote) {
+		t.Error("final vote with 66% of the online weight confirmed the block")
+	}
+
+	if !manager.Vote(types.Address{0xbb}, types.Amount{Lo: 1}, *block.Hash, finalVote) {
+		t.Error("final votes with 67% of the online weight didn't confirm the block")
+	}
+}
+
+func TestCachedVotes(t *testing.T) {
+	manager := newTestElections()
+
+	block := testBlock(0x01, types.Address{0x10})
+
+	// Votes arriving before the block are kept until it does, the latest one per representative
+	manager.Vote(testRepresentative, testWeight, *block.Hash, voteAt(2000))
+	manager.Vote(testRepresentative, testWeight, *block.Hash, voteAt(1000))
+
+	if manager.GetElection(block.Hash) != nil {
+		t.Fatal("vote started an election")
+	}
+
+	manager.AddCandidate(block)
+
+	vote, found := manager.GetElection(block.Hash).Votes[testRepresentative]
+	if !found || vote.Timestamp != 2000 {
+		t.Errorf("counted vote %+v once the block arrived, want the vote signed at 2000", vote)
+	}
+
+	if stats := manager.GetStats(); stats.Replayed != 1 || stats.VoteCache != 0 {
+		t.Errorf("%d votes replayed and %d blocks left in the vote cache, want 1 and 0", stats.Replayed, stats.VoteCache)
+	}
+}
+
+func TestReplayedVoteAfterElectionRestart(t *testing.T) {
+	manager := newTestElections()
+
+	block := testBlock(0x01, types.Address{0x10})
+	manager.AddCandidate(block)
+
+	manager.Vote(testRepresentative, testWeight, *block.Hash, voteAt(1000))
+	if !manager.Vote(testRepresentative, testWeight, *block.Hash, finalVote) {
+		t.Fatal("final vote with all of the online weight didn't confirm the block")
+	}
+
+	// The election ends, and starts again once the block comes back as a candidate
+	manager.Confirmed(block)
+
+	// Replayed while the root isn't elected, it isn't cached to be counted later
+	manager.Vote(testRepresentative, testWeight, *block.Hash, voteAt(1000))
+	if stats := manager.GetStats(); stats.VoteCache != 0 || stats.Stale != 1 {
+		t.Errorf("%d blocks in the vote cache and %d stale votes, want the replayed vote dropped as stale", stats.VoteCache, stats.Stale)
+	}
+
+	manager.AddCandidate(block)
+
+	// Replayed once the root is elected again
+	manager.Vote(testRepresentative, testWeight, *block.Hash, voteAt(1000))
+
+	if vote, found := manager.GetElection(block.Hash).Votes[testRepresentative]; found {
+		t.Errorf("counted replayed vote %+v in the restarted election", vote)
+	}
+
+	if manager.GetStats().Stale != 2 {
+		t.Error("replayed vote wasn't counted as stale")
+	}
+}
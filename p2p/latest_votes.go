@@ -0,0 +1,51 @@
+package p2p
+
+import (
+	"github.com/Shryder/gnano/types"
+	"github.com/Shryder/gnano/utils"
+)
+
+const LATEST_VOTES_SIZE = 262_144 // Representative and root pairs remembered, the ones voted on the longest ago are forgotten first
+
+type latestVoteKey struct {
+	Representative types.Address
+	Root           types.Hash
+}
+
+// Timestamp of the latest vote counted from each representative on each root. Unlike Election.Votes it outlives the
+// election, so an old vote replayed after the election ended can't be counted once the root is elected again.
+type LatestVotes struct {
+	timestamps *utils.LRU // latestVoteKey => uint64
+	roots      *utils.LRU // types.Hash => types.Hash, root of the blocks votes were counted for
+}
+
+func NewLatestVotes(size int) *LatestVotes {
+	return &LatestVotes{
+		timestamps: utils.NewLRU(size),
+		roots:      utils.NewLRU(size),
+	}
+}
+
+func (latest *LatestVotes) Get(representative types.Address, root types.Hash) (uint64, bool) {
+	timestamp, found := latest.timestamps.Get(latestVoteKey{Representative: representative, Root: root})
+	if !found {
+		return 0, false
+	}
+
+	return timestamp.(uint64), true
+}
+
+// Root of a block that was a candidate when a vote for it was counted
+func (latest *LatestVotes) RootOf(hash types.Hash) (types.Hash, bool) {
+	root, found := latest.roots.Get(hash)
+	if !found {
+		return types.Hash{}, false
+	}
+
+	return root.(types.Hash), true
+}
+
+func (latest *LatestVotes) Put(representative types.Address, root types.Hash, hash types.Hash, timestamp uint64) {
+	latest.timestamps.Put(latestVoteKey{Representative: representative, Root: root}, timestamp)
+	latest.roots.Put(hash, root)
+}
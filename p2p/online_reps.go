@@ -0,0 +1,173 @@
+package p2p
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Shryder/gnano/types"
+)
+
+const (
+	ONLINE_REP_TIMEOUT            = 5 * 60 * 1000 // in ms, representatives that didn't vote for this long are considered offline
+	ONLINE_WEIGHT_SAMPLE_INTERVAL = time.Minute
+	ONLINE_WEIGHT_SAMPLES         = 1440 // A day of samples
+
+	DEFAULT_ONLINE_WEIGHT_MINIMUM = "60000000000000000000000000000000000000" // in raw (60M XNO), used when OnlineWeightMinimum isn't set
+)
+
+type OnlineWeightStats struct {
+	Representatives int          `json:"representatives"`
+	Online          types.Amount `json:"online_stake_total"`
+	Trended         types.Amount `json:"trended_stake_total"`
+	Minimum         types.Amount `json:"online_weight_minimum"`
+	QuorumPercent   uint         `json:"online_weight_quorum_percent"`
+	QuorumDelta     types.Amount `json:"quorum_delta"`
+}
+
+// Voting weight of the representatives we receive votes from. Quorum is a share of it, so that blocks can be confirmed
+// without the votes of representatives that are offline.
+type OnlineReps struct {
+	P2PServer *P2P
+
+	LastSeen map[types.Address]int64 // mapping(representative => unix ms of its last vote)
+	Online   types.Amount            // Weight of the representatives in LastSeen
+	Samples  []types.Amount          // Online weight sampled every ONLINE_WEIGHT_SAMPLE_INTERVAL, oldest first
+	Minimum  types.Amount            // The online weight is never considered lower than this
+	Mutex    sync.RWMutex
+}
+
+// Without a minimum the first representative to vote would make up all of the online weight, and reach quorum on its own
+func parseOnlineWeightMinimum(config ConsensusConfig) (types.Amount, error) {
+	minimum_str := config.OnlineWeightMinimum
+	if minimum_str == "" {
+		minimum_str = DEFAULT_ONLINE_WEIGHT_MINIMUM
+	}
+
+	minimum, err := types.AmountFromString(minimum_str)
+	if err != nil {
+		return types.Amount{}, fmt.Errorf("invalid OnlineWeightMinimum: %w", err)
+	}
+
+	if minimum.IsZero() && config.QuorumPercent > 0 {
+		return types.Amount{}, errors.New("OnlineWeightMinimum can't be 0 when QuorumPercent is set")
+	}
+
+	return *minimum, nil
+}
+
+func NewOnlineReps(srv *P2P) OnlineReps {
+	return OnlineReps{
+		P2PServer: srv,
+		LastSeen:  make(map[types.Address]int64),
+		Samples:   make([]types.Amount, 0, ONLINE_WEIGHT_SAMPLES),
+		Mutex:     sync.RWMutex{},
+	}
+}
+
+// Marks a representative as online. Its weight counts right away, the others' is refreshed on the next sample.
+func (reps *OnlineReps) Observe(representative types.Address, weight types.Amount) {
+	reps.Mutex.Lock()
+	defer reps.Mutex.Unlock()
+
+	if _, found := reps.LastSeen[representative]; !found {
+		reps.Online = reps.Online.Add(weight)
+	}
+
+	reps.LastSeen[representative] = time.Now().UnixMilli()
+}
+
+// Drops the representatives that went offline and records the weight of the ones left
+func (reps *OnlineReps) Sample() {
+	now := time.Now().UnixMilli()
+
+	reps.Mutex.Lock()
+	defer reps.Mutex.Unlock()
+
+	online := types.Amount{}
+	for representative, last_seen := range reps.LastSeen {
+		if last_seen+ONLINE_REP_TIMEOUT < now {
+			delete(reps.LastSeen, representative)
+			continue
+		}
+
+		online = online.Add(reps.P2PServer.Database.GetVotingWeight(&representative))
+	}
+
+	reps.Online = online
+
+	if len(reps.Samples) == ONLINE_WEIGHT_SAMPLES {
+		reps.Samples = append(reps.Samples[:0], reps.Samples[1:]...)
+	}
+
+	reps.Samples = append(reps.Samples, online)
+}
+
+// Median of the samples, it doesn't drop when representatives briefly go offline. Callers hold Mutex.
+func (reps *OnlineReps) trended() types.Amount {
+	if len(reps.Samples) == 0 {
+		return types.Amount{}
+	}
+
+	sorted := append([]types.Amount{}, reps.Samples...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Cmp(sorted[j]) < 0
+	})
+
+	return sorted[len(sorted)/2]
+}
+
+// Largest of the current, trended and minimum online weight. Callers hold Mutex.
+func (reps *OnlineReps) onlineWeight() types.Amount {
+	weight := reps.Minimum
+	for _, candidate := range []types.Amount{reps.Online, reps.trended()} {
+		if candidate.Cmp(weight) > 0 {
+			weight = candidate
+		}
+	}
+
+	return weight
+}
+
+// Final vote weight a block needs to be cemented, false when quorum is disabled and only TrustedPRs cement blocks
+func (reps *OnlineReps) QuorumDelta() (types.Amount, bool) {
+	percent := reps.P2PServer.Config.Consensus.QuorumPercent
+	if percent == 0 {
+		return types.Amount{}, false
+	}
+
+	reps.Mutex.RLock()
+	defer reps.Mutex.RUnlock()
+
+	return reps.onlineWeight().Percent(uint64(percent)), true
+}
+
+func (reps *OnlineReps) PeriodicSampling() {
+	for {
+		time.Sleep(ONLINE_WEIGHT_SAMPLE_INTERVAL)
+
+		reps.Sample()
+	}
+}
+
+func (reps *OnlineReps) Start() {
+	go reps.PeriodicSampling()
+}
+
+func (reps *OnlineReps) GetStats() OnlineWeightStats {
+	quorum_delta, _ := reps.QuorumDelta()
+
+	reps.Mutex.RLock()
+	defer reps.Mutex.RUnlock()
+
+	return OnlineWeightStats{
+		Representatives: len(reps.LastSeen),
+		Online:          reps.Online,
+		Trended:         reps.trended(),
+		Minimum:         reps.Minimum,
+		QuorumPercent:   reps.P2PServer.Config.Consensus.QuorumPercent,
+		QuorumDelta:     quorum_delta,
+	}
+}
@@ -0,0 +1,56 @@
+package p2p
+
+import (
+	"testing"
+
+	"github.com/Shryder/gnano/types"
+)
+
+func TestParseOnlineWeightMinimum(t *testing.T) {
+	default_minimum, _ := types.AmountFromString(DEFAULT_ONLINE_WEIGHT_MINIMUM)
+
+	tests := []struct {
+		name    string
+		config  ConsensusConfig
+		minimum types.Amount
+		valid   bool
+	}{
+		{"unset", ConsensusConfig{QuorumPercent: 67}, *default_minimum, true},
+		{"set", ConsensusConfig{QuorumPercent: 67, OnlineWeightMinimum: "1000"}, types.Amount{Lo: 1000}, true},
+		{"zero with quorum", ConsensusConfig{QuorumPercent: 67, OnlineWeightMinimum: "0"}, types.Amount{}, false},
+		{"zero without quorum", ConsensusConfig{OnlineWeightMinimum: "0"}, types.Amount{}, true},
+		{"not a number", ConsensusConfig{QuorumPercent: 67, OnlineWeightMinimum: "sixty million"}, types.Amount{}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			minimum, err := parseOnlineWeightMinimum(test.config)
+			if (err == nil) != test.valid {
+				t.Fatalf("got error %v, want valid: %t", err, test.valid)
+			}
+
+			if minimum != test.minimum {
+				t.Errorf("minimum is %s, want %s", minimum.String(), test.minimum.String())
+			}
+		})
+	}
+}
+
+func TestSmallRepCantReachQuorumAlone(t *testing.T) {
+	manager := newTestElections()
+
+	minimum, err := parseOnlineWeightMinimum(manager.P2PServer.Config.Consensus)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manager.P2PServer.OnlineReps.Minimum = minimum
+
+	block := testBlock(0x01, types.Address{0x10})
+	manager.AddCandidate(block)
+
+	// Nobody else voted yet, so its weight is all of the online weight we've seen
+	if manager.Vote(testRepresentative, types.Amount{Lo: 1000}, *block.Hash, finalVote) {
+		t.Error("final vote of a representative with 1000 raw confirmed the block on an empty online sample")
+	}
+}
@@ -20,14 +20,16 @@ import (
 )
 
 type P2P struct {
-	Config        *Config
-	Server        *net.Listener
-	Database      database.Database
-	VotingEnabled bool
+	Config       *Config
+	TxPoolConfig *TxPoolConfig
+	Server       *net.Listener
+	Database     database.Database
 
 	PeersManager           PeersManager
 	UncheckedBlocksManager UncheckedBlocksManager
 	BootstrapDataManager   BootstrapDataManager
+	ElectionsManager       ElectionsManager
+	OnlineReps             OnlineReps
 
 	NodeKeyPair        NodeKeyPair
 	NodeStartTimestamp uint64
@@ -37,11 +39,11 @@ type P2P struct {
 	GenesisBlock *types.Block
 }
 
-func New(cfg *Config, genesisBlock *types.Block) *P2P {
+func New(cfg *Config, txPoolConfig *TxPoolConfig, genesisBlock *types.Block) *P2P {
 	srv := &P2P{
 		Config:             cfg,
+		TxPoolConfig:       txPoolConfig,
 		NodeStartTimestamp: uint64(time.Now().UnixMilli()),
-		VotingEnabled:      false,
 		GenesisBlock:       genesisBlock,
 	}
 
@@ -49,6 +51,8 @@ func New(cfg *Config, genesisBlock *types.Block) *P2P {
 	srv.PeersManager = NewPeersManager(srv)
 	srv.UncheckedBlocksManager = NewUncheckedBlocksManager(srv)
 	srv.BootstrapDataManager = NewBootstrapDataManager()
+	srv.ElectionsManager = NewElectionsManager(srv)
+	srv.OnlineReps = NewOnlineReps(srv)
 	return srv
 }
 
@@ -208,10 +212,17 @@ func (srv *P2P) Start() {
 	srv.Workers.Start()
 	srv.PeersManager.Start()
 	srv.UncheckedBlocksManager.Start()
+	srv.OnlineReps.Start()
+	srv.ElectionsManager.Start()
 
 	srv.StartListening()
 }
 
+// Writes whatever is left in memory before the database is closed
+func (srv *P2P) Cleanup() {
+	srv.UncheckedBlocksManager.Stop()
+}
+
 func (srv *P2P) LoadOrCreateNodeIdentity() error {
 	node_public_key, node_private_key, err := srv.Database.LoadOrCreateNodeIdentity()
 	if err != nil {
@@ -240,9 +251,17 @@ func (srv *P2P) ValidateAndStart(database database.Database) error {
 
 	log.Println("Public Key:", hex.EncodeToString(srv.NodeKeyPair.PublicKey))
 
+	if srv.Config.Consensus.QuorumPercent > 100 {
+		return errors.New("QuorumPercent can't be above 100")
+	}
+
+	srv.OnlineReps.Minimum, err = parseOnlineWeightMinimum(srv.Config.Consensus)
+	if err != nil {
+		return err
+	}
+
 	// Store genesis block in the ledger if it wasn't stored already.
-	ledgerGenesisBlock := database.Backend.GetBlock(srv.GenesisBlock.Hash)
-	if ledgerGenesisBlock == nil {
+	if !database.HasBlock(srv.GenesisBlock.Hash) {
 		if database.Backend.GetBlockCount() != 0 {
 			return errors.New("genesis block not found locally yet the ledger had more than 1 block in it")
 		}
@@ -251,6 +270,20 @@ func (srv *P2P) ValidateAndStart(database database.Database) error {
 		if err != nil {
 			return errors.New("error storing genesis block in ledger")
 		}
+
+		_, err = database.Backend.CementBlock(srv.GenesisBlock.Hash)
+		if err != nil {
+			return errors.New("error cementing genesis block")
+		}
+	}
+
+	if srv.TxPoolConfig.PersistUnchecked {
+		err = srv.UncheckedBlocksManager.LoadUncheckedTable()
+		if err != nil {
+			return fmt.Errorf("error loading unchecked table: %w", err)
+		}
+
+		go srv.UncheckedBlocksManager.PeriodicFlushes()
 	}
 
 	log.Println("Starting p2p server")
@@ -1,6 +1,9 @@
 package packets
 
 import (
+	"errors"
+	"fmt"
+
 	"github.com/Shryder/gnano/types"
 	"github.com/Shryder/gnano/utils"
 )
@@ -62,16 +65,18 @@ func ParseSendBlock(data []byte) *types.Block {
 	copy(signature[:], data[80:144])
 	copy(work[:], data[144:152])
 
-	hash := utils.Blake2BHash(previous[:], destination[:], data[64:80])
-
-	return &types.Block{
-		Hash:      hash,
+	block := &types.Block{
+		Type:      types.BLOCK_TYPE_SEND,
 		Previous:  &previous,
 		Link:      &destination,
 		Balance:   &balance,
 		Signature: &signature,
 		Work:      &work,
 	}
+
+	block.Hash, _ = HashBlock(block)
+
+	return block
 }
 
 func ParseReceiveBlock(data []byte) *types.Block {
@@ -87,17 +92,19 @@ func ParseReceiveBlock(data []byte) *types.Block {
 	copy(previous[:], data[0:32])
 	copy(source[:], data[32:64])
 	copy(signature[:], data[64:128])
+	copy(work[:], data[128:136])
 
-	hash := utils.Blake2BHash(previous[:], source[:])
-
-	return &types.Block{
+	block := &types.Block{
 		Type:      types.BLOCK_TYPE_RECEIVE,
-		Hash:      hash,
 		Previous:  &previous,
 		Link:      &source,
 		Signature: &signature,
 		Work:      &work,
 	}
+
+	block.Hash, _ = HashBlock(block)
+
+	return block
 }
 
 func ParseOpenBlock(data []byte) *types.Block {
@@ -120,11 +127,8 @@ func ParseOpenBlock(data []byte) *types.Block {
 	copy(signature[:], data[96:160])
 	copy(work[:], data[160:168])
 
-	hash := utils.Blake2BHash(source[:], representative[:], account[:])
-
-	return &types.Block{
+	block := &types.Block{
 		Type:           types.BLOCK_TYPE_OPEN,
-		Hash:           hash,
 		Account:        &account,
 		Previous:       &previous,
 		Link:           &source,
@@ -132,6 +136,10 @@ func ParseOpenBlock(data []byte) *types.Block {
 		Representative: &representative,
 		Work:           &work,
 	}
+
+	block.Hash, _ = HashBlock(block)
+
+	return block
 }
 
 func ParseChangeBlock(data []byte) *types.Block {
@@ -149,16 +157,17 @@ func ParseChangeBlock(data []byte) *types.Block {
 	copy(signature[:], data[64:128])
 	copy(work[:], data[128:136])
 
-	hash := utils.Blake2BHash(previous[:], representative[:])
-
-	return &types.Block{
+	block := &types.Block{
 		Type:           types.BLOCK_TYPE_CHANGE,
-		Hash:           hash,
 		Previous:       &previous,
 		Signature:      &signature,
 		Representative: &representative,
 		Work:           &work,
 	}
+
+	block.Hash, _ = HashBlock(block)
+
+	return block
 }
 
 func ParseStateBlock(data []byte) *types.Block {
@@ -182,19 +191,8 @@ func ParseStateBlock(data []byte) *types.Block {
 	copy(signature[:], data[144:208])
 	copy(work[:], data[208:216])
 
-	state_block_header := append(make([]byte, 31), 0x06)
-	hash := utils.Blake2BHash(
-		state_block_header,
-		account[:],
-		previous[:],
-		representative[:],
-		data[96:112],
-		link[:],
-	)
-
-	return &types.Block{
+	block := &types.Block{
 		Type:           types.BLOCK_TYPE_STATE,
-		Hash:           hash,
 		Account:        &account,
 		Previous:       &previous,
 		Representative: &representative,
@@ -203,4 +201,130 @@ func ParseStateBlock(data []byte) *types.Block {
 		Signature:      &signature,
 		Work:           &work,
 	}
+
+	block.Hash, _ = HashBlock(block)
+
+	return block
+}
+
+// Parses a block from its wire representation, data must not include the block type byte
+func ParseBlock(blockType BlockType, data []byte) (*types.Block, error) {
+	var block *types.Block
+	switch blockType {
+	case BLOCK_TYPE_OPEN:
+		block = ParseOpenBlock(data)
+	case BLOCK_TYPE_STATE:
+		block = ParseStateBlock(data)
+	case BLOCK_TYPE_CHANGE:
+		block = ParseChangeBlock(data)
+	case BLOCK_TYPE_SEND:
+		block = ParseSendBlock(data)
+	case BLOCK_TYPE_RECEIVE:
+		block = ParseReceiveBlock(data)
+	default:
+		return nil, errors.New("Can't parse this block type")
+	}
+
+	if block == nil {
+		return nil, fmt.Errorf("invalid size %d for block type %d", len(data), blockType)
+	}
+
+	return block, nil
+}
+
+// Wire representation of a block without the block type byte, the inverse of ParseBlock
+func SerializeBlock(block *types.Block) ([]byte, error) {
+	if block.Signature == nil || block.Work == nil {
+		return nil, errors.New("block is missing its signature or work")
+	}
+
+	var fields [][]byte
+	switch block.Type {
+	case types.BLOCK_TYPE_SEND:
+		if block.Previous == nil || block.Link == nil || block.Balance == nil {
+			return nil, errors.New("send block is missing fields")
+		}
+
+		fields = [][]byte{block.Previous[:], block.Link[:], block.Balance.BytesBE()}
+	case types.BLOCK_TYPE_RECEIVE:
+		if block.Previous == nil || block.Link == nil {
+			return nil, errors.New("receive block is missing fields")
+		}
+
+		fields = [][]byte{block.Previous[:], block.Link[:]}
+	case types.BLOCK_TYPE_OPEN:
+		if block.Link == nil || block.Representative == nil || block.Account == nil {
+			return nil, errors.New("open block is missing fields")
+		}
+
+		fields = [][]byte{block.Link[:], block.Representative[:], block.Account[:]}
+	case types.BLOCK_TYPE_CHANGE:
+		if block.Previous == nil || block.Representative == nil {
+			return nil, errors.New("change block is missing fields")
+		}
+
+		fields = [][]byte{block.Previous[:], block.Representative[:]}
+	case types.BLOCK_TYPE_STATE:
+		if block.Account == nil || block.Previous == nil || block.Representative == nil || block.Balance == nil || block.Link == nil {
+			return nil, errors.New("state block is missing fields")
+		}
+
+		fields = [][]byte{block.Account[:], block.Previous[:], block.Representative[:], block.Balance.BytesBE(), block.Link[:]}
+	default:
+		return nil, fmt.Errorf("unknown block type %d", block.Type)
+	}
+
+	data := make([]byte, 0, BlockType(block.Type).Size())
+	for _, field := range append(fields, block.Signature[:], block.Work[:]) {
+		data = append(data, field...)
+	}
+
+	return data, nil
+}
+
+// Computes the hash of a block from its contents, which is what its signature signs
+func HashBlock(block *types.Block) (*types.Hash, error) {
+	switch block.Type {
+	case types.BLOCK_TYPE_SEND:
+		if block.Previous == nil || block.Link == nil || block.Balance == nil {
+			return nil, errors.New("send block is missing fields")
+		}
+
+		return utils.Blake2BHash(block.Previous[:], block.Link[:], block.Balance.BytesBE()), nil
+	case types.BLOCK_TYPE_RECEIVE:
+		if block.Previous == nil || block.Link == nil {
+			return nil, errors.New("receive block is missing fields")
+		}
+
+		return utils.Blake2BHash(block.Previous[:], block.Link[:]), nil
+	case types.BLOCK_TYPE_OPEN:
+		if block.Link == nil || block.Representative == nil || block.Account == nil {
+			return nil, errors.New("open block is missing fields")
+		}
+
+		return utils.Blake2BHash(block.Link[:], block.Representative[:], block.Account[:]), nil
+	case types.BLOCK_TYPE_CHANGE:
+		if block.Previous == nil || block.Representative == nil {
+			return nil, errors.New("change block is missing fields")
+		}
+
+		return utils.Blake2BHash(block.Previous[:], block.Representative[:]), nil
+	case types.BLOCK_TYPE_STATE:
+		if block.Account == nil || block.Previous == nil || block.Representative == nil || block.Balance == nil || block.Link == nil {
+			return nil, errors.New("state block is missing fields")
+		}
+
+		state_block_header := append(make([]byte, 31), 0x06)
+
+		return utils.Blake2BHash(
+			state_block_header,
+			block.Account[:],
+			block.Previous[:],
+			block.Representative[:],
+			block.Balance.BytesBE(),
+			block.Link[:],
+		), nil
+	}
+
+	return nil, fmt.Errorf("unknown block type %d", block.Type)
 }
@@ -2,7 +2,6 @@ package packets
 
 import (
 	"bufio"
-	"errors"
 	"io"
 
 	"github.com/Shryder/gnano/types"
@@ -82,20 +81,7 @@ func (reader *PacketReader) ReadBlock(blockType BlockType) (*types.Block, error)
 		return nil, err
 	}
 
-	switch blockType {
-	case BLOCK_TYPE_OPEN:
-		return ParseOpenBlock(block_data), nil
-	case BLOCK_TYPE_STATE:
-		return ParseStateBlock(block_data), nil
-	case BLOCK_TYPE_CHANGE:
-		return ParseChangeBlock(block_data), nil
-	case BLOCK_TYPE_SEND:
-		return ParseSendBlock(block_data), nil
-	case BLOCK_TYPE_RECEIVE:
-		return ParseReceiveBlock(block_data), nil
-	}
-
-	return nil, errors.New("Can't parse this block type")
+	return ParseBlock(blockType, block_data)
 }
 
 func (reader PacketReader) Read(p []byte) (int, error) {
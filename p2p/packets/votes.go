@@ -3,6 +3,7 @@ package packets
 import (
 	"encoding/binary"
 	"math"
+	"time"
 
 	"github.com/Shryder/gnano/types"
 )
@@ -27,8 +28,18 @@ type ConfirmAckByHashes struct {
 	Hashes *[]*types.Hash
 }
 
+// Unix milliseconds the vote was generated at, with its lowest 4 bits replaced by the vote's duration bits.
+// Final votes have every bit set.
 type TimestampAndVoteDuration [8]byte
 
+const VOTE_DURATION_BITS_MASK = 0xf
+
+type VoteTimestamp struct {
+	Timestamp    uint64 // Unix milliseconds, the lowest 4 bits are always 0. math.MaxUint64 for final votes.
+	DurationBits uint8
+	Final        bool
+}
+
 func (tvd *TimestampAndVoteDuration) Uint64() uint64 {
 	return binary.LittleEndian.Uint64(tvd[:])
 }
@@ -37,6 +48,24 @@ func (tvd *TimestampAndVoteDuration) IsFinalVote() bool {
 	return tvd.Uint64() == math.MaxUint64
 }
 
+func (tvd *TimestampAndVoteDuration) Decode() VoteTimestamp {
+	if tvd.IsFinalVote() {
+		return VoteTimestamp{Timestamp: math.MaxUint64, DurationBits: VOTE_DURATION_BITS_MASK, Final: true}
+	}
+
+	value := tvd.Uint64()
+
+	return VoteTimestamp{
+		Timestamp:    value &^ VOTE_DURATION_BITS_MASK,
+		DurationBits: uint8(value & VOTE_DURATION_BITS_MASK),
+	}
+}
+
+// How long the representative considers the vote valid, from 16ms up to about 9 minutes
+func (timestamp VoteTimestamp) Duration() time.Duration {
+	return time.Duration(uint64(1)<<(timestamp.DurationBits+4)) * time.Millisecond
+}
+
 func (pair *HashPair) ToSlice() []byte {
 	return append((*pair.Hash)[:], (*pair.Root)[:]...)
 }
@@ -0,0 +1,37 @@
+package packets
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestDecodeVoteTimestamp(t *testing.T) {
+	tests := []struct {
+		name     string
+		raw      uint64
+		decoded  VoteTimestamp
+		duration time.Duration
+	}{
+		{"shortest duration", 1_650_000_000_000, VoteTimestamp{Timestamp: 1_650_000_000_000}, 16 * time.Millisecond},
+		{"duration bits", 1_650_000_000_000 | 0x9, VoteTimestamp{Timestamp: 1_650_000_000_000, DurationBits: 0x9}, 8192 * time.Millisecond},
+		{"final vote", math.MaxUint64, VoteTimestamp{Timestamp: math.MaxUint64, DurationBits: VOTE_DURATION_BITS_MASK, Final: true}, 524288 * time.Millisecond},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var tvd TimestampAndVoteDuration
+			binary.LittleEndian.PutUint64(tvd[:], test.raw)
+
+			decoded := tvd.Decode()
+			if decoded != test.decoded {
+				t.Errorf("decoded %+v, want %+v", decoded, test.decoded)
+			}
+
+			if decoded.Duration() != test.duration {
+				t.Errorf("duration is %s, want %s", decoded.Duration(), test.duration)
+			}
+		})
+	}
+}
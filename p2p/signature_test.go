@@ -0,0 +1,68 @@
+package p2p
+
+import (
+	"testing"
+
+	"github.com/Shryder/gnano/types"
+	"github.com/shryder/ed25519-blake2b"
+)
+
+type testKey struct {
+	Address    types.Address
+	PrivateKey ed25519.PrivateKey
+}
+
+func newTestKey(t *testing.T) testKey {
+	public_key, private_key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var address types.Address
+	copy(address[:], public_key)
+
+	return testKey{Address: address, PrivateKey: private_key}
+}
+
+func (key testKey) sign(block *types.Block) *types.Block {
+	var signature types.Signature
+	copy(signature[:], ed25519.Sign(key.PrivateKey, block.Hash[:]))
+	block.Signature = &signature
+
+	return block
+}
+
+func TestValidateSignature(t *testing.T) {
+	genesis_key, account_key := newTestKey(t), newTestKey(t)
+	srv := New(&Config{}, &TxPoolConfig{}, &types.Block{Hash: &types.Hash{0xff}, Account: &genesis_key.Address})
+
+	var epoch_link types.Link
+	copy(epoch_link[:], "epoch v1 block")
+
+	state := func(hash byte, link types.Link) *types.Block {
+		return &types.Block{Type: types.BLOCK_TYPE_STATE, Hash: &types.Hash{hash}, Account: &account_key.Address, Link: &link}
+	}
+
+	// Legacy change blocks don't have a link
+	change := &types.Block{Type: types.BLOCK_TYPE_CHANGE, Hash: &types.Hash{0x01}, Account: &account_key.Address}
+
+	tests := []struct {
+		name  string
+		block *types.Block
+		valid bool
+	}{
+		{"legacy change", account_key.sign(change), true},
+		{"state block", account_key.sign(state(0x02, types.Link{})), true},
+		{"state block signed by someone else", genesis_key.sign(state(0x03, types.Link{})), false},
+		{"epoch block signed by the epoch signer", genesis_key.sign(state(0x04, epoch_link)), true},
+		{"epoch block signed by its account", account_key.sign(state(0x05, epoch_link)), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if valid := srv.UncheckedBlocksManager.ValidateSignature(test.block); valid != test.valid {
+				t.Errorf("signature is valid: %t, want %t", valid, test.valid)
+			}
+		})
+	}
+}
@@ -34,7 +34,7 @@ func (srv *P2P) SendTelemetryAck(peer *networking.PeerNode) error {
 
 	packet.WriteBE(srv.NodeKeyPair.PublicKey)                                 // node_id
 	packet.WriteBE(srv.Database.Backend.GetBlockCount())                      // block count
-	packet.WriteBE(srv.Database.Backend.GetBlockCount())                      // cemented count
+	packet.WriteBE(srv.Database.Backend.GetCementedCount())                   // cemented count
 	packet.WriteBE(srv.UncheckedBlocksManager.UncheckedBlocksCount())         // unchecked count
 	packet.WriteBE(srv.Database.Backend.GetAccountCount())                    // account count
 	packet.WriteBE(uint64(0))                                                 // bandwidth count
@@ -1,120 +1,366 @@
 package p2p
 
 import (
+	"container/list"
 	"log"
+	"sort"
 	"sync"
 	"time"
 
+	"github.com/Shryder/gnano/database/ledger"
+	"github.com/Shryder/gnano/p2p/networking"
 	"github.com/Shryder/gnano/types"
 	"github.com/shryder/ed25519-blake2b"
 )
 
+type queuedBlock struct {
+	Block  *types.Block
+	Source string
+}
+
+// Who sent an unchecked block and when, used to expire it and to evict fairly once the table is full
+type uncheckedEntry struct {
+	Received int64         // Unix milliseconds of when the block was first seen
+	Account  types.Address // Zero for legacy blocks, they don't carry their account
+	Source   string        // IP of the peer that sent the block, empty for blocks reloaded from the database
+	State    *pendingState // Set once the block is validated, nil while it waits for its previous block or source
+
+	// Positions in the arrival ordered lists, so that the block can be unlinked from them
+	arrival        *list.Element
+	accountArrival *list.Element
+	sourceArrival  *list.Element
+}
+
 type UncheckedBlocksManager struct {
 	P2PServer *P2P
 
-	// Blocks that we are looking for votes for
-	BatchVoteRequests      map[types.Hash]*types.Hash // mapping(hash => root)
-	BatchVoteRequestsMutex sync.RWMutex
-
-	Queue                chan *types.Block
+	Queue                chan queuedBlock
 	UncheckedBlocks      map[types.Hash]*types.Block
+	Entries              map[types.Hash]*uncheckedEntry
+	Arrivals             *list.List                         // Hashes of every unchecked block, oldest at the front
+	AccountBlocks        map[types.Address]*list.List       // Hashes of the unchecked blocks of each account, oldest at the front
+	SourceBlocks         map[string]*list.List              // Hashes of the unchecked blocks sent by each peer, oldest at the front
+	Dependents           map[types.Hash]map[types.Hash]bool // Hashes of the unchecked blocks that can't be stored before each block
+	Candidates           []*types.Block                     // Inserted blocks waiting to be added to their election once UncheckedBlocksMutex is released
+	Stats                UncheckedStats
+	ProcessedCounts      [BLOCK_STATUS_COUNT]uint64 // Blocks checked by the block processor, by outcome
 	UncheckedBlocksMutex sync.RWMutex
+
+	// Changes to the unchecked table that weren't written to the database yet, a nil entry is a removal
+	PendingWrites      map[types.Hash]*ledger.UncheckedInfo
+	PendingWritesMutex sync.Mutex
+	FlushMutex         sync.Mutex // Keeps flushes in order so that an older write can't land after a newer removal
+
+	StopFlushing    chan bool // Closed on Stop to stop PeriodicFlushes
+	FlushingStopped chan bool // Closed by PeriodicFlushes once it returns
 }
 
 func NewUncheckedBlocksManager(srv *P2P) UncheckedBlocksManager {
 	return UncheckedBlocksManager{
-		P2PServer:         srv,
-		Queue:             make(chan *types.Block, 256_000),
-		UncheckedBlocks:   make(map[types.Hash]*types.Block, 256_000),
-		BatchVoteRequests: make(map[types.Hash]*types.Hash),
+		P2PServer:       srv,
+		Queue:           make(chan queuedBlock, 256_000),
+		UncheckedBlocks: make(map[types.Hash]*types.Block, 256_000),
+		Entries:         make(map[types.Hash]*uncheckedEntry, 256_000),
+		Arrivals:        list.New(),
+		AccountBlocks:   make(map[types.Address]*list.List),
+		SourceBlocks:    make(map[string]*list.List),
+		Dependents:      make(map[types.Hash]map[types.Hash]bool),
+		PendingWrites:   make(map[types.Hash]*ledger.UncheckedInfo),
+
+		UncheckedBlocksMutex: sync.RWMutex{},
+
+		StopFlushing:    make(chan bool),
+		FlushingStopped: make(chan bool),
+	}
+}
 
-		BatchVoteRequestsMutex: sync.RWMutex{},
-		UncheckedBlocksMutex:   sync.RWMutex{},
+// Blocks that have to be in the ledger before this one can be stored: its previous block and, for receives, the send.
+// Whether a state block is a receive depends on its previous block's balance, so any link that isn't an epoch link
+// is indexed. The link of a send is an account, no block hash matches it.
+func uncheckedDependencies(block *types.Block) []types.Hash {
+	dependencies := make([]types.Hash, 0, 2)
+	if !block.IsOpenBlock() {
+		dependencies = append(dependencies, *block.Previous)
 	}
+
+	switch block.Type {
+	case types.BLOCK_TYPE_OPEN, types.BLOCK_TYPE_RECEIVE, types.BLOCK_TYPE_STATE:
+		if _, is_epoch := ledger.EpochOfLink(block.Link); block.Link != nil && !is_epoch && *block.Link != (types.Link{}) {
+			dependencies = append(dependencies, types.Hash(*block.Link))
+		}
+	}
+
+	return dependencies
 }
 
-// Add block to list of unconfirmed blocks to ask peers for confirmations
-func (manager *UncheckedBlocksManager) RequestVotesOnBlock(block *types.Block) {
-	manager.BatchVoteRequestsMutex.Lock()
-	defer manager.BatchVoteRequestsMutex.Unlock()
+// Blocks have to be inserted in the order they were received. Callers hold UncheckedBlocksMutex.
+func (manager *UncheckedBlocksManager) insert(block *types.Block, received int64, source string) {
+	entry := &uncheckedEntry{Received: received, Source: source}
+	if block.Account != nil {
+		entry.Account = *block.Account
+	}
 
-	manager.BatchVoteRequests[*block.Hash] = block.Root()
+	if manager.AccountBlocks[entry.Account] == nil {
+		manager.AccountBlocks[entry.Account] = list.New()
+	}
+
+	if manager.SourceBlocks[entry.Source] == nil {
+		manager.SourceBlocks[entry.Source] = list.New()
+	}
+
+	entry.arrival = manager.Arrivals.PushBack(*block.Hash)
+	entry.accountArrival = manager.AccountBlocks[entry.Account].PushBack(*block.Hash)
+	entry.sourceArrival = manager.SourceBlocks[entry.Source].PushBack(*block.Hash)
+
+	for _, dependency := range uncheckedDependencies(block) {
+		if manager.Dependents[dependency] == nil {
+			manager.Dependents[dependency] = make(map[types.Hash]bool)
+		}
+
+		manager.Dependents[dependency][*block.Hash] = true
+	}
+
+	manager.UncheckedBlocks[*block.Hash] = block
+	manager.Entries[*block.Hash] = entry
+
+	// AddCandidate can cement the block, which needs UncheckedBlocksMutex, so it's only called once the lock is released
+	manager.Candidates = append(manager.Candidates, block)
 }
 
-func (manager *UncheckedBlocksManager) InsertToUncheckedTable(block *types.Block) {
+// Adds the blocks inserted since the last call to their elections. Callers don't hold UncheckedBlocksMutex.
+func (manager *UncheckedBlocksManager) addCandidates() {
 	manager.UncheckedBlocksMutex.Lock()
-	defer manager.UncheckedBlocksMutex.Unlock()
+	candidates := manager.Candidates
+	manager.Candidates = nil
+	manager.UncheckedBlocksMutex.Unlock()
 
-	manager.UncheckedBlocks[*block.Hash] = block
+	for _, block := range candidates {
+		if manager.Get(block.Hash) == nil {
+			// Dropped again before it made it into an election
+			continue
+		}
+
+		// Votes are requested for every candidate of the election once it's scheduled
+		if manager.P2PServer.ElectionsManager.AddCandidate(block) {
+			log.Println("Fork detected on root", block.Root().ToHexString(), "with block", block.Hash.ToHexString())
+		}
+	}
 }
 
-func (manager *UncheckedBlocksManager) ValidateSignature(block *types.Block) bool {
-	epochV2 := "epoch v2 block"
-	if string(block.Link[0:len(epochV2)]) == epochV2 {
-		return true
+// Callers hold UncheckedBlocksMutex
+func (manager *UncheckedBlocksManager) remove(hash types.Hash) {
+	entry, found := manager.Entries[hash]
+	if !found {
+		return
 	}
 
-	epochV1 := "epoch v1 block"
-	if string(block.Link[0:len(epochV1)]) == epochV1 {
-		return true
+	block := manager.UncheckedBlocks[hash]
+	delete(manager.UncheckedBlocks, hash)
+	delete(manager.Entries, hash)
+
+	manager.Arrivals.Remove(entry.arrival)
+
+	manager.AccountBlocks[entry.Account].Remove(entry.accountArrival)
+	if manager.AccountBlocks[entry.Account].Len() == 0 {
+		delete(manager.AccountBlocks, entry.Account)
 	}
 
-	return ed25519.Verify(ed25519.PublicKey(block.Account[:]), block.Hash[:], block.Signature[:])
+	manager.SourceBlocks[entry.Source].Remove(entry.sourceArrival)
+	if manager.SourceBlocks[entry.Source].Len() == 0 {
+		delete(manager.SourceBlocks, entry.Source)
+	}
+
+	for _, dependency := range uncheckedDependencies(block) {
+		delete(manager.Dependents[dependency], hash)
+		if len(manager.Dependents[dependency]) == 0 {
+			delete(manager.Dependents, dependency)
+		}
+	}
+
+	manager.P2PServer.ElectionsManager.RemoveCandidate(hash)
+	manager.schedulePersist(hash, nil)
 }
 
-// Processes new incoming blocks (from bulk_pull_response/publish) and adds them to unchecked table
-func (manager *UncheckedBlocksManager) ProcessNewBlocks() {
-	for {
-		block := <-manager.Queue
+// Drops a block that lost its election, along with every unchecked block building on it or receiving from it
+func (manager *UncheckedBlocksManager) DropFork(hash types.Hash) {
+	manager.UncheckedBlocksMutex.Lock()
+	defer manager.UncheckedBlocksMutex.Unlock()
 
-		valid_signature := manager.ValidateSignature(block)
-		if !valid_signature {
-			log.Println("Encountered block with invalid signature:", block.Hash.ToHexString(), string(block.Link[:]), *block)
-			continue
+	queue := []types.Hash{hash}
+	for len(queue) > 0 {
+		dropped := queue[0]
+		queue = queue[1:]
+
+		for dependent := range manager.Dependents[dropped] {
+			queue = append(queue, dependent)
+		}
+
+		if _, found := manager.Entries[dropped]; found {
+			manager.remove(dropped)
+			manager.Stats.Forks++
 		}
+	}
+}
 
-		manager.InsertToUncheckedTable(block)
-		// manager.RequestVotesOnBlock(block) // TODO: Maybe wait a little before requesting other nodes for votes, depending on how we received the block
+// Queues a change to be written by the next flush, info is nil for removals
+func (manager *UncheckedBlocksManager) schedulePersist(hash types.Hash, info *ledger.UncheckedInfo) {
+	if !manager.P2PServer.TxPoolConfig.PersistUnchecked {
+		return
 	}
+
+	manager.PendingWritesMutex.Lock()
+	manager.PendingWrites[hash] = info
+	manager.PendingWritesMutex.Unlock()
 }
 
-// Returns a list of up to 64 hash pairs that we are looking for confirmations for
-func (manager *UncheckedBlocksManager) UnconfirmedHashPairs() [][]byte {
-	hash_pairs := make([][]byte, 0)
+// Writes the queued changes to the database, they're queued again if that fails
+func (manager *UncheckedBlocksManager) Flush() error {
+	manager.FlushMutex.Lock()
+	defer manager.FlushMutex.Unlock()
 
-	manager.BatchVoteRequestsMutex.Lock()
-	defer manager.BatchVoteRequestsMutex.Unlock()
+	manager.PendingWritesMutex.Lock()
+	pending := manager.PendingWrites
+	manager.PendingWrites = make(map[types.Hash]*ledger.UncheckedInfo)
+	manager.PendingWritesMutex.Unlock()
 
-	batchVoteRequestsCount := uint(len(manager.BatchVoteRequests))
-	max := uint(1)
-	if batchVoteRequestsCount < max {
-		max = batchVoteRequestsCount
+	if len(pending) == 0 {
+		return nil
 	}
 
-	for hash, root := range manager.BatchVoteRequests {
-		if max <= 0 {
-			break
+	writes := make([]*ledger.UncheckedInfo, 0, len(pending))
+	removals := make([]types.Hash, 0)
+	for hash, info := range pending {
+		if info == nil {
+			removals = append(removals, hash)
+		} else {
+			writes = append(writes, info)
 		}
+	}
 
-		hash_pairs = append(hash_pairs, append(hash[:], root[:]...))
-		delete(manager.BatchVoteRequests, hash)
-		max--
+	backend := manager.P2PServer.Database.Backend
+	err := backend.PutUnchecked(writes)
+	if err == nil {
+		err = backend.DeleteUnchecked(removals)
 	}
 
-	return hash_pairs
+	if err != nil {
+		// Changes queued since then are newer, they win
+		manager.PendingWritesMutex.Lock()
+		for hash, info := range pending {
+			if _, found := manager.PendingWrites[hash]; !found {
+				manager.PendingWrites[hash] = info
+			}
+		}
+		manager.PendingWritesMutex.Unlock()
+
+		return err
+	}
+
+	return nil
 }
 
-// Process unchecked blocks that we don't have enough (or no) confirmations on
-func (manager *UncheckedBlocksManager) BatchRequestVotesOnUncheckedBlocks() {
+func (manager *UncheckedBlocksManager) PeriodicFlushes() {
+	defer close(manager.FlushingStopped)
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
 	for {
-		time.Sleep(time.Second * 3)
+		select {
+		case <-manager.StopFlushing:
+			return
+		case <-ticker.C:
+			err := manager.Flush()
+			if err != nil {
+				log.Println("Error writing unchecked table to database:", err)
+			}
+		}
+	}
+}
 
-		hash_pairs := manager.UnconfirmedHashPairs()
-		if len(hash_pairs) == 0 {
-			continue
+// Stops the periodic flushes and writes whatever is still pending
+func (manager *UncheckedBlocksManager) Stop() {
+	if !manager.P2PServer.TxPoolConfig.PersistUnchecked {
+		return
+	}
+
+	close(manager.StopFlushing)
+	<-manager.FlushingStopped
+
+	err := manager.Flush()
+	if err != nil {
+		log.Println("Error writing unchecked table to database:", err)
+	}
+}
+
+// Reloads the unchecked table stored by a previous run. Blocks that expired or made it into the ledger since are dropped.
+func (manager *UncheckedBlocksManager) LoadUncheckedTable() error {
+	now := time.Now().UnixMilli()
+	stale := make([]types.Hash, 0)
+	entries := make([]ledger.UncheckedInfo, 0)
+
+	err := manager.P2PServer.Database.Backend.ForEachUnchecked(func(entry *ledger.UncheckedInfo) error {
+		hash := *entry.Block.Hash
+		if manager.isExpired(entry.Received, now) || manager.P2PServer.Database.HasBlock(&hash) {
+			stale = append(stale, hash)
+			return nil
+		}
+
+		entries = append(entries, *entry)
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Received < entries[j].Received
+	})
+
+	manager.UncheckedBlocksMutex.Lock()
+	for i := range entries {
+		manager.insert(&entries[i].Block, entries[i].Received, "")
+	}
+
+	// The ledger moved on since the table was stored, every block is validated again
+	for i := range entries {
+		hash := *entries[i].Block.Hash
+		if _, found := manager.Entries[hash]; found && manager.stateOf(hash) == nil && manager.settle(hash) {
+			manager.releaseHeld(hash)
 		}
+	}
+
+	// MaxUncheckedCount may have been lowered since the table was stored
+	manager.enforceLimits(now)
+
+	loaded := len(manager.UncheckedBlocks)
+	manager.UncheckedBlocksMutex.Unlock()
 
-		manager.P2PServer.Workers.ConfirmReq.RequestVotesOnTheseBlocks(hash_pairs, nil)
+	manager.addCandidates()
+
+	log.Println("Loaded", loaded, "unchecked blocks, dropped", len(stale), "that expired or were already in the ledger")
+
+	return manager.P2PServer.Database.Backend.DeleteUnchecked(stale)
+}
+
+// Epoch blocks are signed by the epoch signer, the genesis account, every other block by its own account
+func (manager *UncheckedBlocksManager) ValidateSignature(block *types.Block) bool {
+	signer := block.Account
+	if _, is_epoch := ledger.EpochOfLink(block.Link); is_epoch && block.Type == types.BLOCK_TYPE_STATE {
+		signer = manager.P2PServer.GenesisBlock.Account
+	}
+
+	return ed25519.Verify(ed25519.PublicKey(signer[:]), block.Hash[:], block.Signature[:])
+}
+
+// Processes new incoming blocks (from bulk_pull_response/publish) and adds them to unchecked table
+func (manager *UncheckedBlocksManager) ProcessNewBlocks() {
+	for {
+		queued := <-manager.Queue
+
+		manager.ProcessBlock(queued.Block, queued.Source)
 	}
 }
 
@@ -145,7 +391,20 @@ func (manager *UncheckedBlocksManager) GetUncheckedBlocks() map[types.Hash]*type
 
 func (manager *UncheckedBlocksManager) Start() {
 	go manager.ProcessNewBlocks()
-	go manager.BatchRequestVotesOnUncheckedBlocks()
+	go manager.PeriodicExpiry()
+}
+
+// Unchecked blocks that can't be stored before the block with this hash
+func (manager *UncheckedBlocksManager) GetDependents(hash *types.Hash) []types.Hash {
+	manager.UncheckedBlocksMutex.RLock()
+	defer manager.UncheckedBlocksMutex.RUnlock()
+
+	dependents := make([]types.Hash, 0, len(manager.Dependents[*hash]))
+	for dependent := range manager.Dependents[*hash] {
+		dependents = append(dependents, dependent)
+	}
+
+	return dependents
 }
 
 func (manager *UncheckedBlocksManager) Get(hash *types.Hash) *types.Block {
@@ -157,22 +416,22 @@ func (manager *UncheckedBlocksManager) Get(hash *types.Hash) *types.Block {
 
 func (manager *UncheckedBlocksManager) Remove(hash *types.Hash) {
 	manager.UncheckedBlocksMutex.Lock()
-	delete(manager.UncheckedBlocks, *hash)
+	manager.remove(*hash)
 	manager.UncheckedBlocksMutex.Unlock()
 }
 
-func (manager *UncheckedBlocksManager) Add(block *types.Block) {
+// peer is the node that sent the block, it's held accountable for it when the table is full
+func (manager *UncheckedBlocksManager) Add(block *types.Block, peer *networking.PeerNode) {
 	unchecked_block := manager.Get(block.Hash)
 	if unchecked_block != nil {
 		// Block is already in the unchecked table
 		return
 	}
 
-	ledger_block := manager.P2PServer.Database.Backend.GetBlock(block.Hash)
-	if ledger_block != nil {
-		// Block is already cemented
+	if manager.P2PServer.Database.HasBlock(block.Hash) {
+		// Block is already in the ledger, or was pruned from it
 		return
 	}
 
-	manager.Queue <- block
+	manager.Queue <- queuedBlock{Block: block, Source: peerSource(peer)}
 }
@@ -0,0 +1,147 @@
+package p2p
+
+import (
+	"container/list"
+	"log"
+	"net"
+	"time"
+
+	"github.com/Shryder/gnano/p2p/networking"
+	"github.com/Shryder/gnano/types"
+)
+
+type UncheckedStats struct {
+	Count           uint              `json:"count"`
+	Expired         uint64            `json:"expired"`          // Dropped after UncheckedLifetime
+	EvictedAccounts uint64            `json:"evicted_accounts"` // Dropped because their account had the most unchecked blocks
+	EvictedPeers    uint64            `json:"evicted_peers"`    // Dropped because the peer that sent them had sent the most unchecked blocks
+	Forks           uint64            `json:"forks"`            // Dropped because they lost their election, or built on a block that did
+	Processed       map[string]uint64 `json:"processed"`        // Blocks checked by the block processor, by outcome
+}
+
+// Peers are told apart by IP, a node opening several connections is still a single source
+func peerSource(peer *networking.PeerNode) string {
+	if peer == nil {
+		return ""
+	}
+
+	host, _, err := net.SplitHostPort(peer.Conn.RemoteAddr().String())
+	if err != nil {
+		return peer.Conn.RemoteAddr().String()
+	}
+
+	return host
+}
+
+func (manager *UncheckedBlocksManager) isExpired(received int64, now int64) bool {
+	lifetime := int64(manager.P2PServer.TxPoolConfig.UncheckedLifetime)
+
+	return lifetime != 0 && received+lifetime < now
+}
+
+// Callers hold UncheckedBlocksMutex
+func (manager *UncheckedBlocksManager) removeExpired(now int64) {
+	if manager.P2PServer.TxPoolConfig.UncheckedLifetime == 0 {
+		return
+	}
+
+	for oldest := manager.Arrivals.Front(); oldest != nil; oldest = manager.Arrivals.Front() {
+		hash := oldest.Value.(types.Hash)
+		if !manager.isExpired(manager.Entries[hash].Received, now) {
+			return
+		}
+
+		manager.remove(hash)
+		manager.Stats.Expired++
+	}
+}
+
+// Returns the key of the largest group along with its size, keys that nothing can be blamed on are skipped
+func largestAccountGroup(groups map[types.Address]*list.List) (types.Address, int) {
+	var largest types.Address
+	size := 0
+	for account, hashes := range groups {
+		if account != (types.Address{}) && hashes.Len() > size {
+			largest, size = account, hashes.Len()
+		}
+	}
+
+	return largest, size
+}
+
+func largestSourceGroup(groups map[string]*list.List) (string, int) {
+	largest := ""
+	size := 0
+	for source, hashes := range groups {
+		if source != "" && hashes.Len() > size {
+			largest, size = source, hashes.Len()
+		}
+	}
+
+	return largest, size
+}
+
+// Evicts a block from whichever account or peer holds the largest share of the table, so that a single
+// account or peer flooding us with blocks can't push out everyone else's. Callers hold UncheckedBlocksMutex.
+func (manager *UncheckedBlocksManager) evictOne() {
+	account, account_size := largestAccountGroup(manager.AccountBlocks)
+	source, source_size := largestSourceGroup(manager.SourceBlocks)
+
+	// The newest block of the group goes, the oldest blocks of a chain are the ones its next blocks depend on
+	switch {
+	case account_size == 0 && source_size == 0:
+		// Only legacy blocks reloaded from the database are left
+		manager.remove(manager.Arrivals.Back().Value.(types.Hash))
+		manager.Stats.EvictedAccounts++
+	case account_size >= source_size:
+		manager.remove(manager.AccountBlocks[account].Back().Value.(types.Hash))
+		manager.Stats.EvictedAccounts++
+	default:
+		manager.remove(manager.SourceBlocks[source].Back().Value.(types.Hash))
+		manager.Stats.EvictedPeers++
+	}
+}
+
+// Expired blocks go first, then blocks are evicted one by one until the table fits in MaxUncheckedCount.
+// Callers hold UncheckedBlocksMutex.
+func (manager *UncheckedBlocksManager) enforceLimits(now int64) {
+	max_count := int(manager.P2PServer.TxPoolConfig.MaxUncheckedCount)
+	if max_count == 0 || len(manager.UncheckedBlocks) <= max_count {
+		return
+	}
+
+	manager.removeExpired(now)
+	for len(manager.UncheckedBlocks) > max_count {
+		manager.evictOne()
+	}
+}
+
+func (manager *UncheckedBlocksManager) PeriodicExpiry() {
+	for {
+		time.Sleep(time.Second)
+
+		manager.UncheckedBlocksMutex.Lock()
+		before := manager.Stats.Expired
+		manager.removeExpired(time.Now().UnixMilli())
+		expired := manager.Stats.Expired - before
+		manager.UncheckedBlocksMutex.Unlock()
+
+		if expired > 0 {
+			log.Println("Expired", expired, "unchecked blocks")
+		}
+	}
+}
+
+func (manager *UncheckedBlocksManager) GetStats() UncheckedStats {
+	manager.UncheckedBlocksMutex.RLock()
+	defer manager.UncheckedBlocksMutex.RUnlock()
+
+	stats := manager.Stats
+	stats.Count = uint(len(manager.UncheckedBlocks))
+	stats.Processed = make(map[string]uint64, BLOCK_STATUS_COUNT)
+	for status, count := range manager.ProcessedCounts {
+		stats.Processed[BlockStatus(status).String()] = count
+	}
+
+	return stats
+}
@@ -0,0 +1,79 @@
+package p2p
+
+import (
+	"github.com/Shryder/gnano/types"
+	"github.com/Shryder/gnano/utils"
+)
+
+const (
+	DEFAULT_VOTE_CACHE_SIZE = 65536 // Used when VoteCacheSize isn't set
+	VOTE_CACHE_MAX_VOTERS   = 64    // Votes kept per block, the lightest one makes room for a heavier one
+)
+
+// Votes for blocks that aren't candidates in any election yet, they're counted once the block arrives instead of
+// being requested again. Blocks that were voted for the longest ago are forgotten first.
+// Only used while holding ElectionsManager.Mutex.
+type VoteCache struct {
+	votes *utils.LRU // types.Hash => map[types.Address]ElectionVote
+}
+
+func NewVoteCache(size uint) *VoteCache {
+	if size == 0 {
+		size = DEFAULT_VOTE_CACHE_SIZE
+	}
+
+	return &VoteCache{votes: utils.NewLRU(int(size))}
+}
+
+func (cache *VoteCache) Add(representative types.Address, vote ElectionVote) {
+	var voters map[types.Address]ElectionVote
+	if cached, found := cache.votes.Get(vote.Hash); found {
+		voters = cached.(map[types.Address]ElectionVote)
+	} else {
+		voters = make(map[types.Address]ElectionVote)
+		cache.votes.Put(vote.Hash, voters)
+	}
+
+	if previous, found := voters[representative]; found {
+		if vote.Timestamp > previous.Timestamp {
+			voters[representative] = vote
+		}
+
+		return
+	}
+
+	if len(voters) >= VOTE_CACHE_MAX_VOTERS {
+		lightest, lightest_vote := types.Address{}, ElectionVote{}
+		first := true
+		for voter, cached := range voters {
+			if first || cached.Weight.Cmp(lightest_vote.Weight) < 0 {
+				lightest, lightest_vote, first = voter, cached, false
+			}
+		}
+
+		if vote.Weight.Cmp(lightest_vote.Weight) <= 0 {
+			return
+		}
+
+		delete(voters, lightest)
+	}
+
+	voters[representative] = vote
+}
+
+// Removes and returns the votes cached for the block with this hash
+func (cache *VoteCache) Take(hash types.Hash) map[types.Address]ElectionVote {
+	cached, found := cache.votes.Get(hash)
+	if !found {
+		return nil
+	}
+
+	cache.votes.Remove(hash)
+
+	return cached.(map[types.Address]ElectionVote)
+}
+
+// Blocks that have votes cached
+func (cache *VoteCache) Len() int {
+	return cache.votes.Len()
+}
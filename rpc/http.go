@@ -2,12 +2,14 @@ package rpc
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"time"
 
+	"github.com/Shryder/gnano/database"
 	"github.com/Shryder/gnano/p2p"
 	"github.com/Shryder/gnano/types"
 	"github.com/gorilla/mux"
@@ -104,6 +106,137 @@ func (srv *HTTPRPCServer) HandlePeersInfo(bodyStr []byte) ([]byte, error) {
 	return responseJSON, nil
 }
 
+func (srv *HTTPRPCServer) HandleReceivable(bodyStr []byte) ([]byte, error) {
+	var body struct {
+		Params struct {
+			Account string `json:"account"`
+		} `json:"params"`
+	}
+
+	err := json.Unmarshal(bodyStr, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	account, err := types.DecodeNanoAddress(body.Params.Account)
+	if err != nil {
+		return nil, err
+	}
+
+	type ReceivableEntry struct {
+		Amount types.Amount `json:"amount"`
+		Source string       `json:"source"`
+	}
+
+	blocks := make(map[string]ReceivableEntry)
+	for hash, info := range srv.P2PServer.Database.Backend.GetReceivables(account) {
+		entry := ReceivableEntry{Amount: info.Amount}
+		if info.Source != nil {
+			entry.Source = info.Source.ToNanoAddress()
+		}
+
+		blocks[hash.ToHexString()] = entry
+	}
+
+	return json.Marshal(struct {
+		Blocks map[string]ReceivableEntry `json:"blocks"`
+	}{blocks})
+}
+
+func (srv *HTTPRPCServer) HandleCacheStats(bodyStr []byte) ([]byte, error) {
+	cache, enabled := srv.P2PServer.Database.Backend.(*database.CachedBackend)
+	if !enabled {
+		return nil, errors.New("the database cache is disabled")
+	}
+
+	return json.Marshal(cache.Stats())
+}
+
+func (srv *HTTPRPCServer) HandleUncheckedStats(bodyStr []byte) ([]byte, error) {
+	return json.Marshal(srv.P2PServer.UncheckedBlocksManager.GetStats())
+}
+
+func (srv *HTTPRPCServer) HandleElectionStats(bodyStr []byte) ([]byte, error) {
+	return json.Marshal(srv.P2PServer.ElectionsManager.GetStats())
+}
+
+func (srv *HTTPRPCServer) HandleConfirmationQuorum(bodyStr []byte) ([]byte, error) {
+	return json.Marshal(srv.P2PServer.OnlineReps.GetStats())
+}
+
+func (srv *HTTPRPCServer) HandleBlockCount(bodyStr []byte) ([]byte, error) {
+	return json.Marshal(struct {
+		Count     string `json:"count"`
+		Unchecked string `json:"unchecked"`
+		Cemented  string `json:"cemented"`
+		Pruned    string `json:"pruned"`
+	}{
+		Count:     fmt.Sprintf("%d", srv.P2PServer.Database.Backend.GetBlockCount()),
+		Unchecked: fmt.Sprintf("%d", srv.P2PServer.UncheckedBlocksManager.UncheckedBlocksCount()),
+		Cemented:  fmt.Sprintf("%d", srv.P2PServer.Database.Backend.GetCementedCount()),
+		Pruned:    fmt.Sprintf("%d", srv.P2PServer.Database.Backend.GetPrunedCount()),
+	})
+}
+
+func (srv *HTTPRPCServer) HandleBlockInfo(bodyStr []byte) ([]byte, error) {
+	var body struct {
+		Params struct {
+			Hash string `json:"hash"`
+		} `json:"params"`
+	}
+
+	err := json.Unmarshal(bodyStr, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := types.StringToHash(body.Params.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	block := srv.P2PServer.Database.Backend.GetBlock(hash)
+	if block == nil && srv.P2PServer.Database.Backend.IsBlockPruned(hash) {
+		return nil, fmt.Errorf("block %s was pruned, it is confirmed but its contents are no longer stored", hash.ToHexString())
+	}
+
+	if block == nil {
+		return nil, fmt.Errorf("block %s not found", hash.ToHexString())
+	}
+
+	sideband := srv.P2PServer.Database.Backend.GetBlockSideband(hash)
+	if sideband == nil {
+		return nil, fmt.Errorf("block %s has no sideband", hash.ToHexString())
+	}
+
+	successor := types.Hash{}
+	if sideband.Successor != nil {
+		successor = *sideband.Successor
+	}
+
+	return json.Marshal(struct {
+		BlockAccount   string             `json:"block_account"`
+		Balance        types.Amount       `json:"balance"`
+		Height         string             `json:"height"`
+		LocalTimestamp string             `json:"local_timestamp"`
+		Successor      string             `json:"successor"`
+		Epoch          byte               `json:"epoch"`
+		Subtype        types.BlockSubtype `json:"subtype"`
+		Confirmed      bool               `json:"confirmed"`
+		Contents       *types.Block       `json:"contents"`
+	}{
+		BlockAccount:   sideband.Account.ToNanoAddress(),
+		Balance:        sideband.Balance,
+		Height:         sideband.Height.String(),
+		LocalTimestamp: fmt.Sprintf("%d", sideband.Timestamp),
+		Successor:      successor.ToHexString(),
+		Epoch:          sideband.Epoch,
+		Subtype:        sideband.Subtype,
+		Confirmed:      srv.P2PServer.Database.IsBlockCemented(hash),
+		Contents:       block,
+	})
+}
+
 func (srv *HTTPRPCServer) Handle(w http.ResponseWriter, r *http.Request) {
 	r.Header.Add("Content-Type", "application/json")
 
@@ -130,11 +263,25 @@ func (srv *HTTPRPCServer) Handle(w http.ResponseWriter, r *http.Request) {
 
 	switch reqBody.Method {
 	case "cemented_block_count":
-		response = []byte(fmt.Sprintf("%d", srv.P2PServer.Database.Backend.GetBlockCount()))
+		response = []byte(fmt.Sprintf("%d", srv.P2PServer.Database.Backend.GetCementedCount()))
+	case "block_count":
+		response, err = srv.HandleBlockCount(bodyStr)
+	case "block_info":
+		response, err = srv.HandleBlockInfo(bodyStr)
+	case "confirmation_quorum":
+		response, err = srv.HandleConfirmationQuorum(bodyStr)
+	case "receivable":
+		response, err = srv.HandleReceivable(bodyStr)
 	case "gnano_memoryViewer":
 		response, err = srv.HandleMemoryViewer(bodyStr)
 	case "gnano_peersInfo":
 		response, err = srv.HandlePeersInfo(bodyStr)
+	case "gnano_cacheStats":
+		response, err = srv.HandleCacheStats(bodyStr)
+	case "gnano_uncheckedStats":
+		response, err = srv.HandleUncheckedStats(bodyStr)
+	case "gnano_electionStats":
+		response, err = srv.HandleElectionStats(bodyStr)
 	default:
 		err = fmt.Errorf("method %s is not supported", reqBody.Method)
 	}
@@ -13,10 +13,29 @@ func (u Amount) Bytes() []byte {
 	return Uint128(u).Big().Bytes()
 }
 
+// Fixed size (16 bytes) big endian representation, same layout used on the wire
+func (u Amount) BytesBE() []byte {
+	amount_bytes := make([]byte, 16)
+	binary.BigEndian.PutUint64(amount_bytes[:8], u.Hi)
+	binary.BigEndian.PutUint64(amount_bytes[8:], u.Lo)
+
+	return amount_bytes
+}
+
 func (u Amount) Add(v Amount) Amount {
 	return Amount(Uint128(v).Add(Uint128(u)))
 }
 
+// Panics if v > u
+func (u Amount) Sub(v Amount) Amount {
+	return Amount(Uint128(u).Sub(Uint128(v)))
+}
+
+// Rounded down to a multiple of percent raw
+func (u Amount) Percent(percent uint64) Amount {
+	return Amount(Uint128(u).Div64(100).Mul64(percent))
+}
+
 func (u Amount) IsZero() bool {
 	return Uint128(u).IsZero()
 }
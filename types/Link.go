@@ -11,7 +11,7 @@ func (link *Link) MarshalJSON() ([]byte, error) {
 	return []byte(`"` + hex.EncodeToString(link[:]) + `"`), nil
 }
 
-func (link Link) UnmarshalJSON(link_hex []byte) error {
+func (link *Link) UnmarshalJSON(link_hex []byte) error {
 	decoded, err := hex.DecodeString(strings.Trim(string(link_hex), `"`))
 	if err != nil {
 		return err
@@ -2,7 +2,19 @@ package types
 
 import "math/big"
 
+const (
+	EPOCH_0 byte = 0
+	EPOCH_1 byte = 1
+	EPOCH_2 byte = 2
+)
+
+// Ledger data stored alongside every block, it isn't part of the signed block contents
 type Sideband struct {
-	Height    *big.Int `json:"height"`
-	Timestamp uint     `json:"timestamp"`
+	Height    *big.Int     `json:"height"`
+	Successor *Hash        `json:"successor"` // nil while the block is its account's frontier
+	Account   *Address     `json:"account"`
+	Balance   Amount       `json:"balance"`
+	Timestamp uint         `json:"timestamp"`
+	Epoch     byte         `json:"epoch"`
+	Subtype   BlockSubtype `json:"subtype"` // Missing from sidebands stored before subtypes were resolved
 }
@@ -15,7 +15,7 @@ func (signature *Signature) MarshalJSON() ([]byte, error) {
 	return []byte(`"` + hex.EncodeToString(signature[:]) + `"`), nil
 }
 
-func (signature Signature) UnmarshalJSON(signature_bytes []byte) error {
+func (signature *Signature) UnmarshalJSON(signature_bytes []byte) error {
 	decoded, err := hex.DecodeString(strings.Trim(string(signature_bytes), `"`))
 	if err != nil {
 		return err
@@ -0,0 +1,51 @@
+package types
+
+import "fmt"
+
+// What a block does to its account. Legacy blocks have one type per subtype, state blocks only tell by comparing
+// their balance with the previous block's.
+type BlockSubtype byte
+
+const (
+	BLOCK_SUBTYPE_UNKNOWN BlockSubtype = 0 // Sidebands whose previous block was pruned before subtypes were stored
+	BLOCK_SUBTYPE_SEND    BlockSubtype = 1
+	BLOCK_SUBTYPE_RECEIVE BlockSubtype = 2
+	BLOCK_SUBTYPE_OPEN    BlockSubtype = 3
+	BLOCK_SUBTYPE_CHANGE  BlockSubtype = 4
+	BLOCK_SUBTYPE_EPOCH   BlockSubtype = 5
+)
+
+var subtypeNames = map[BlockSubtype]string{
+	BLOCK_SUBTYPE_UNKNOWN: "unknown",
+	BLOCK_SUBTYPE_SEND:    "send",
+	BLOCK_SUBTYPE_RECEIVE: "receive",
+	BLOCK_SUBTYPE_OPEN:    "open",
+	BLOCK_SUBTYPE_CHANGE:  "change",
+	BLOCK_SUBTYPE_EPOCH:   "epoch",
+}
+
+func (subtype BlockSubtype) String() string {
+	name, found := subtypeNames[subtype]
+	if !found {
+		return "unknown"
+	}
+
+	return name
+}
+
+// Subtypes are written by name in JSON, both in RPC responses and in the stored sidebands
+func (subtype BlockSubtype) MarshalText() ([]byte, error) {
+	return []byte(subtype.String()), nil
+}
+
+func (subtype *BlockSubtype) UnmarshalText(text []byte) error {
+	for value, name := range subtypeNames {
+		if name == string(text) {
+			*subtype = value
+
+			return nil
+		}
+	}
+
+	return fmt.Errorf("invalid block subtype %s", string(text))
+}
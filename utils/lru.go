@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"container/list"
+	"sync"
+)
+
+type lruEntry struct {
+	key   interface{}
+	value interface{}
+}
+
+// Fixed size cache that evicts the least recently used entry once it's full, safe for concurrent use
+type LRU struct {
+	capacity int
+	entries  map[interface{}]*list.Element
+	order    *list.List // Most recently used at the front
+
+	mutex sync.Mutex
+}
+
+func NewLRU(capacity int) *LRU {
+	return &LRU{
+		capacity: capacity,
+		entries:  make(map[interface{}]*list.Element, capacity),
+		order:    list.New(),
+	}
+}
+
+func (lru *LRU) Get(key interface{}) (interface{}, bool) {
+	lru.mutex.Lock()
+	defer lru.mutex.Unlock()
+
+	element, found := lru.entries[key]
+	if !found {
+		return nil, false
+	}
+
+	lru.order.MoveToFront(element)
+
+	return element.Value.(*lruEntry).value, true
+}
+
+// Adds or replaces the entry, returns the key that was evicted to make room for it, if any
+func (lru *LRU) Put(key interface{}, value interface{}) (evicted interface{}, was_evicted bool) {
+	lru.mutex.Lock()
+	defer lru.mutex.Unlock()
+
+	if element, found := lru.entries[key]; found {
+		element.Value.(*lruEntry).value = value
+		lru.order.MoveToFront(element)
+
+		return nil, false
+	}
+
+	lru.entries[key] = lru.order.PushFront(&lruEntry{key: key, value: value})
+	if lru.order.Len() <= lru.capacity {
+		return nil, false
+	}
+
+	oldest := lru.order.Back()
+	lru.order.Remove(oldest)
+	delete(lru.entries, oldest.Value.(*lruEntry).key)
+
+	return oldest.Value.(*lruEntry).key, true
+}
+
+func (lru *LRU) Remove(key interface{}) {
+	lru.mutex.Lock()
+	defer lru.mutex.Unlock()
+
+	if element, found := lru.entries[key]; found {
+		lru.order.Remove(element)
+		delete(lru.entries, key)
+	}
+}
+
+func (lru *LRU) Purge() {
+	lru.mutex.Lock()
+	defer lru.mutex.Unlock()
+
+	lru.entries = make(map[interface{}]*list.Element, lru.capacity)
+	lru.order.Init()
+}
+
+func (lru *LRU) Len() int {
+	lru.mutex.Lock()
+	defer lru.mutex.Unlock()
+
+	return lru.order.Len()
+}